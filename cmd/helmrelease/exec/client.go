@@ -0,0 +1,153 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// errReadOnly is returned by the writes of the offline client, the commands never change the cluster
+var errReadOnly = errors.New("the offline client is read-only")
+
+// offlineClient is a read-only client of the Secrets and ConfigMaps of the files
+type offlineClient struct {
+	secrets    map[types.NamespacedName]*corev1.Secret
+	configMaps map[types.NamespacedName]*corev1.ConfigMap
+}
+
+var _ client.Client = &offlineClient{}
+
+// newOfflineClient returns a client of the Secrets and ConfigMaps, the other objects are ignored
+func newOfflineClient(objects []client.Object) *offlineClient {
+	c := &offlineClient{
+		secrets:    map[types.NamespacedName]*corev1.Secret{},
+		configMaps: map[types.NamespacedName]*corev1.ConfigMap{},
+	}
+
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *corev1.Secret:
+			c.secrets[client.ObjectKeyFromObject(o)] = o
+		case *corev1.ConfigMap:
+			c.configMaps[client.ObjectKeyFromObject(o)] = o
+		}
+	}
+
+	return c
+}
+
+// Get copies the Secret or ConfigMap of the key into obj
+func (c *offlineClient) Get(_ context.Context, key client.ObjectKey, obj client.Object) error {
+	switch o := obj.(type) {
+	case *corev1.Secret:
+		secret, ok := c.secrets[key]
+		if !ok {
+			return apierrors.NewNotFound(corev1.Resource("secrets"), key.Name)
+		}
+
+		secret.DeepCopyInto(o)
+	case *corev1.ConfigMap:
+		configMap, ok := c.configMaps[key]
+		if !ok {
+			return apierrors.NewNotFound(corev1.Resource("configmaps"), key.Name)
+		}
+
+		configMap.DeepCopyInto(o)
+	default:
+		return fmt.Errorf("the offline client can not get %T", obj)
+	}
+
+	return nil
+}
+
+// List lists the Secrets or ConfigMaps that match the namespace and label selector of the options
+func (c *offlineClient) List(_ context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+
+	matches := func(obj client.Object) bool {
+		if listOpts.Namespace != "" && obj.GetNamespace() != listOpts.Namespace {
+			return false
+		}
+
+		return listOpts.LabelSelector == nil || listOpts.LabelSelector.Matches(labels.Set(obj.GetLabels()))
+	}
+
+	switch l := list.(type) {
+	case *corev1.SecretList:
+		l.Items = []corev1.Secret{}
+
+		for _, secret := range c.secrets {
+			if matches(secret) {
+				l.Items = append(l.Items, *secret.DeepCopy())
+			}
+		}
+	case *corev1.ConfigMapList:
+		l.Items = []corev1.ConfigMap{}
+
+		for _, configMap := range c.configMaps {
+			if matches(configMap) {
+				l.Items = append(l.Items, *configMap.DeepCopy())
+			}
+		}
+	default:
+		return fmt.Errorf("the offline client can not list %T", list)
+	}
+
+	return nil
+}
+
+func (c *offlineClient) Create(context.Context, client.Object, ...client.CreateOption) error {
+	return errReadOnly
+}
+
+func (c *offlineClient) Delete(context.Context, client.Object, ...client.DeleteOption) error {
+	return errReadOnly
+}
+
+func (c *offlineClient) Update(context.Context, client.Object, ...client.UpdateOption) error {
+	return errReadOnly
+}
+
+func (c *offlineClient) Patch(context.Context, client.Object, client.Patch, ...client.PatchOption) error {
+	return errReadOnly
+}
+
+func (c *offlineClient) DeleteAllOf(context.Context, client.Object, ...client.DeleteAllOfOption) error {
+	return errReadOnly
+}
+
+func (c *offlineClient) Status() client.StatusWriter {
+	return c
+}
+
+func (c *offlineClient) Scheme() *runtime.Scheme {
+	return scheme.Scheme
+}
+
+func (c *offlineClient) RESTMapper() meta.RESTMapper {
+	return nil
+}
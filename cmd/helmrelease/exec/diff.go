@@ -0,0 +1,202 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pmezard/go-difflib/difflib"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+
+	"github.com/stolostron/multicloud-operators-subscription-release/pkg/controller/helmrelease"
+)
+
+// diff prints the differences between the manifests of the releases deployed in the cluster and the manifests of
+// the HelmReleases, like kubectl diff it returns 1 when they differ. The sources read their Secrets and ConfigMaps
+// in the cluster, like the controller.
+func diff(o *HelmReleaseCMDOptions, stdin io.Reader, stdout io.Writer) (int, error) {
+	helmReleases, _, err := readObjects(o.Files, o.Namespace, stdin)
+	if err != nil {
+		return 2, err
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return 2, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return 2, err
+	}
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		return 2, err
+	}
+
+	capabilities, err := clusterCapabilities(clientset)
+	if err != nil {
+		return 2, err
+	}
+
+	code := 0
+
+	for _, hr := range helmReleases {
+		loaded, err := helmrelease.LoadChart(c, hr)
+		if err != nil {
+			return 2, fmt.Errorf("HelmRelease %s: %w", nsn(hr), err)
+		}
+
+		rel, err := loaded.Render(hr, capabilities)
+		if err != nil {
+			return 2, fmt.Errorf("HelmRelease %s: %w", nsn(hr), err)
+		}
+
+		live := ""
+
+		deployed, err := storage.Init(driver.NewSecrets(clientset.CoreV1().Secrets(hr.Namespace))).Deployed(hr.Name)
+
+		switch {
+		case err == nil:
+			live = deployed.Manifest
+		case errors.Is(err, driver.ErrReleaseNotFound) || errors.Is(err, driver.ErrNoDeployedReleases):
+			fmt.Fprintf(stdout, "# HelmRelease %s has no deployed release\n", nsn(hr))
+		default:
+			return 2, fmt.Errorf("HelmRelease %s: failed to get the deployed release: %w", nsn(hr), err)
+		}
+
+		changed, err := diffManifests(stdout, live, rel.Manifest)
+		if err != nil {
+			return 2, fmt.Errorf("HelmRelease %s: %w", nsn(hr), err)
+		}
+
+		if changed {
+			code = 1
+		}
+	}
+
+	return code, nil
+}
+
+// clusterCapabilities returns the Kubernetes version and the API versions of the cluster, that the controller
+// renders the charts with
+func clusterCapabilities(clientset kubernetes.Interface) (*chartutil.Capabilities, error) {
+	version, err := clientset.Discovery().ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the Kubernetes version of the cluster: %w", err)
+	}
+
+	apiVersions, err := action.GetVersionSet(clientset.Discovery())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the API versions of the cluster: %w", err)
+	}
+
+	return &chartutil.Capabilities{
+		KubeVersion: chartutil.KubeVersion{Version: version.GitVersion, Major: version.Major, Minor: version.Minor},
+		APIVersions: apiVersions,
+	}, nil
+}
+
+// diffManifests writes the unified diff of each object of the manifests that differs and returns true if any does
+func diffManifests(w io.Writer, live, rendered string) (bool, error) {
+	liveObjects, err := manifestObjects(live)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse the deployed manifest: %w", err)
+	}
+
+	renderedObjects, err := manifestObjects(rendered)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse the rendered manifest: %w", err)
+	}
+
+	keys := []string{}
+
+	for key := range liveObjects {
+		keys = append(keys, key)
+	}
+
+	for key := range renderedObjects {
+		if _, ok := liveObjects[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	changed := false
+
+	for _, key := range keys {
+		if liveObjects[key] == renderedObjects[key] {
+			continue
+		}
+
+		changed = true
+
+		text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(liveObjects[key]),
+			B:        difflib.SplitLines(renderedObjects[key]),
+			FromFile: "live/" + key,
+			ToFile:   "rendered/" + key,
+			Context:  3,
+		})
+		if err != nil {
+			return false, err
+		}
+
+		fmt.Fprint(w, text)
+	}
+
+	return changed, nil
+}
+
+// manifestObjects returns the objects of the manifest by kind, namespace and name
+func manifestObjects(manifest string) (map[string]string, error) {
+	objects := map[string]string{}
+
+	for _, content := range releaseutil.SplitManifests(manifest) {
+		obj := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(content), &obj.Object); err != nil {
+			return nil, err
+		}
+
+		if len(obj.Object) == 0 {
+			continue
+		}
+
+		key := obj.GetKind() + "/" + obj.GetName()
+		if obj.GetNamespace() != "" {
+			key = obj.GetKind() + "/" + obj.GetNamespace() + "/" + obj.GetName()
+		}
+
+		objects[key] = strings.TrimSpace(content) + "\n"
+	}
+
+	return objects, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const liveManifest = `---
+# Source: nginx/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: nginx
+spec:
+  ports:
+  - port: 80
+---
+# Source: nginx/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: nginx
+  namespace: apps
+data:
+  replicas: "1"
+`
+
+func TestDiffManifests(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	changed, err := diffManifests(out, liveManifest, liveManifest)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Empty(t, out.String())
+
+	rendered := `---
+# Source: nginx/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: nginx
+spec:
+  ports:
+  - port: 8080
+---
+# Source: nginx/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+`
+
+	changed, err = diffManifests(out, liveManifest, rendered)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Contains(t, out.String(), "--- live/ConfigMap/apps/nginx\n+++ rendered/ConfigMap/apps/nginx\n")
+	assert.Contains(t, out.String(), "--- live/Deployment/nginx\n+++ rendered/Deployment/nginx\n")
+	assert.Contains(t, out.String(), "--- live/Service/nginx\n+++ rendered/Service/nginx\n")
+	assert.Contains(t, out.String(), "-  - port: 80\n+  - port: 8080\n")
+
+	_, err = diffManifests(out, liveManifest, "kind: [")
+	assert.Error(t, err)
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	helmlint "helm.sh/helm/v3/pkg/lint"
+	"helm.sh/helm/v3/pkg/lint/support"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/stolostron/multicloud-operators-subscription-release/pkg/controller/helmrelease"
+	"github.com/stolostron/multicloud-operators-subscription-release/pkg/release"
+)
+
+// lint checks that the chart of each HelmRelease can be fetched from its sources, that its values match the
+// chart and that the chart passes helm lint and renders. It returns 1 when a HelmRelease has an error.
+func lint(o *HelmReleaseCMDOptions, stdin io.Reader, stdout io.Writer) (int, error) {
+	helmReleases, c, err := offlineObjects(o, stdin)
+	if err != nil {
+		return 1, err
+	}
+
+	capabilities, err := o.capabilities()
+	if err != nil {
+		return 1, err
+	}
+
+	code := 0
+
+	for _, hr := range helmReleases {
+		problems, failed := lintHelmRelease(o, c, hr, capabilities)
+
+		for _, problem := range problems {
+			fmt.Fprintf(stdout, "HelmRelease %s: %s\n", nsn(hr), problem)
+		}
+
+		if failed {
+			code = 1
+		} else {
+			fmt.Fprintf(stdout, "HelmRelease %s: OK\n", nsn(hr))
+		}
+	}
+
+	return code, nil
+}
+
+// lintHelmRelease returns the problems of the HelmRelease and true when one of them fails the lint
+func lintHelmRelease(o *HelmReleaseCMDOptions, c client.Client, hr *appv1.HelmRelease,
+	capabilities *chartutil.Capabilities) ([]string, bool) {
+	if len(hr.Repo.CandidateSources()) == 0 {
+		return []string{"[ERROR] repo: no chart source"}, true
+	}
+
+	loaded, err := helmrelease.LoadChart(c, hr)
+	if err != nil {
+		return []string{"[ERROR] repo: " + err.Error()}, true
+	}
+
+	problems := []string{}
+	failed := false
+
+	err = release.ValidateChartValues(loaded.Chart, loaded.Values, o.Strict || hr.Validation.RejectsUnknownValues())
+
+	var invalid *release.ValuesInvalidError
+
+	switch {
+	case errors.As(err, &invalid):
+		for _, e := range invalid.Errors {
+			problems = append(problems, "[ERROR] values: "+e)
+		}
+
+		failed = true
+	case err != nil:
+		problems = append(problems, "[ERROR] values: "+err.Error())
+		failed = true
+	}
+
+	for _, msg := range helmlint.All(loaded.Dir, loaded.Values, hr.Namespace, o.Strict).Messages {
+		if msg.Severity < support.WarningSev {
+			continue
+		}
+
+		problems = append(problems, msg.Error())
+
+		if msg.Severity == support.ErrorSev || o.Strict {
+			failed = true
+		}
+	}
+
+	if _, err := loaded.Render(hr, capabilities); err != nil {
+		problems = append(problems, "[ERROR] render: "+err.Error())
+		failed = true
+	}
+
+	return problems, failed
+}
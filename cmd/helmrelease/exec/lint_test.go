@@ -0,0 +1,67 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	dir, err := ioutil.TempDir("/tmp", "helmrelease")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	noSource := `apiVersion: apps.open-cluster-management.io/v1
+kind: HelmRelease
+metadata:
+  name: test
+repo:
+  chartName: subscription-release-test-1
+`
+
+	tests := []struct {
+		name    string
+		objects string
+		strict  bool
+		code    int
+		stdout  string
+	}{
+		{name: "valid", objects: chartObjects(t, "  enabled: true\n"), code: 0, stdout: "HelmRelease default/test: OK\n"},
+		{name: "no source", objects: noSource, code: 1, stdout: "HelmRelease default/test: [ERROR] repo: no chart source\n"},
+		{name: "unknown value", objects: chartObjects(t, "  unknown: true\n"), strict: true, code: 1,
+			stdout: "HelmRelease default/test: [ERROR] values: "},
+		{name: "render error", objects: chartObjects(t, "  subscriptionrelease: []\n"), code: 1,
+			stdout: "HelmRelease default/test: [ERROR] render: "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &HelmReleaseCMDOptions{Files: []string{"-"}, Namespace: "default", ChartsDir: dir, Strict: tt.strict}
+			stdout := &bytes.Buffer{}
+
+			code, err := lint(o, strings.NewReader(tt.objects), stdout)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.code, code, stdout.String())
+			assert.Contains(t, stdout.String(), tt.stdout)
+		})
+	}
+}
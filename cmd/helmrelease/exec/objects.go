@@ -0,0 +1,162 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	appv2 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v2"
+)
+
+// readObjects reads the HelmReleases of the files, converted to v1, and the Secrets and ConfigMaps of their sources
+func readObjects(files []string, namespace string, stdin io.Reader) ([]*appv1.HelmRelease, []client.Object, error) {
+	helmReleases := []*appv1.HelmRelease{}
+	objects := []client.Object{}
+
+	for _, file := range files {
+		fileHelmReleases, fileObjects, err := readFile(file, namespace, stdin)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		helmReleases = append(helmReleases, fileHelmReleases...)
+		objects = append(objects, fileObjects...)
+	}
+
+	if len(helmReleases) == 0 {
+		return nil, nil, errors.New("no HelmRelease in the files")
+	}
+
+	return helmReleases, objects, nil
+}
+
+// readFile reads the objects of the file, or of stdin when the file is -
+func readFile(file, namespace string, stdin io.Reader) ([]*appv1.HelmRelease, []client.Object, error) {
+	r := stdin
+
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		defer f.Close()
+
+		r = f
+	}
+
+	helmReleases := []*appv1.HelmRelease{}
+	objects := []client.Object{}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+
+	for {
+		u := &unstructured.Unstructured{}
+
+		err := decoder.Decode(&u.Object)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+
+		if len(u.Object) == 0 {
+			continue
+		}
+
+		if u.GetNamespace() == "" {
+			u.SetNamespace(namespace)
+		}
+
+		hr, obj, err := convertObject(u)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s %s of %s: %w", u.GetKind(), u.GetName(), file, err)
+		}
+
+		if hr != nil {
+			helmReleases = append(helmReleases, hr)
+		} else {
+			objects = append(objects, obj)
+		}
+	}
+
+	return helmReleases, objects, nil
+}
+
+// convertObject returns the v1 HelmRelease, or the Secret or ConfigMap, of the object
+func convertObject(u *unstructured.Unstructured) (*appv1.HelmRelease, client.Object, error) {
+	gvk := u.GroupVersionKind()
+
+	switch {
+	case gvk == appv1.SchemeGroupVersion.WithKind("HelmRelease"):
+		hr := &appv1.HelmRelease{}
+
+		return hr, nil, fromUnstructured(u, hr)
+	case gvk == appv2.SchemeGroupVersion.WithKind("HelmRelease"):
+		v2hr := &appv2.HelmRelease{}
+		if err := fromUnstructured(u, v2hr); err != nil {
+			return nil, nil, err
+		}
+
+		hr := &appv1.HelmRelease{}
+
+		return hr, nil, v2hr.ConvertTo(hr)
+	case gvk == corev1.SchemeGroupVersion.WithKind("Secret"):
+		secret := &corev1.Secret{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, secret); err != nil {
+			return nil, nil, err
+		}
+
+		// the API server merges the stringData into the data
+		for key, value := range secret.StringData {
+			if secret.Data == nil {
+				secret.Data = map[string][]byte{}
+			}
+
+			secret.Data[key] = []byte(value)
+		}
+
+		return nil, secret, nil
+	case gvk == corev1.SchemeGroupVersion.WithKind("ConfigMap"):
+		configMap := &corev1.ConfigMap{}
+
+		return nil, configMap, runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, configMap)
+	}
+
+	return nil, nil, fmt.Errorf("unsupported kind %s, the files can hold HelmReleases, Secrets and ConfigMaps", gvk)
+}
+
+// fromUnstructured converts the object through JSON, like the API server, as the spec of a v1 HelmRelease is
+// not a structured type
+func fromUnstructured(u *unstructured.Unstructured, obj interface{}) error {
+	data, err := json.Marshal(u.Object)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, obj)
+}
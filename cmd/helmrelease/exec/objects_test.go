@@ -0,0 +1,122 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const objectsYAML = `apiVersion: apps.open-cluster-management.io/v2
+kind: HelmRelease
+metadata:
+  name: v2
+  namespace: apps
+spec:
+  chart:
+    name: nginx
+    version: 1.0.0
+  source:
+    type: helmrepo
+    helmRepo:
+      urls:
+      - https://charts.example.com/nginx-1.0.0.tgz
+    secretRef:
+      name: credentials
+  values:
+    replicaCount: 2
+---
+apiVersion: apps.open-cluster-management.io/v1
+kind: HelmRelease
+metadata:
+  name: v1
+repo:
+  chartName: nginx
+spec:
+  replicaCount: 3
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+  namespace: apps
+data:
+  user: YWRtaW4=
+stringData:
+  password: secret
+---
+`
+
+func TestReadObjects(t *testing.T) {
+	helmReleases, objects, err := readObjects([]string{"-"}, "default", strings.NewReader(objectsYAML))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if assert.Len(t, helmReleases, 2) {
+		v2 := helmReleases[0]
+		assert.Equal(t, "apps/v2", nsn(v2))
+		assert.Equal(t, "nginx", v2.Repo.ChartName)
+		assert.Equal(t, "1.0.0", v2.Repo.Version)
+		assert.Equal(t, appv1.HelmRepoSourceType, v2.Repo.Source.SourceType)
+		assert.Equal(t, []string{"https://charts.example.com/nginx-1.0.0.tgz"}, v2.Repo.Source.HelmRepo.Urls)
+		assert.Equal(t, "credentials", v2.Repo.SecretRef.Name)
+		assert.Equal(t, map[string]interface{}{"replicaCount": float64(2)}, v2.Spec)
+
+		v1 := helmReleases[1]
+		assert.Equal(t, "default/v1", nsn(v1))
+		assert.Equal(t, map[string]interface{}{"replicaCount": float64(3)}, v1.Spec)
+	}
+
+	if assert.Len(t, objects, 1) {
+		secret, ok := objects[0].(*corev1.Secret)
+		if assert.True(t, ok) {
+			assert.Equal(t, map[string][]byte{"user": []byte("admin"), "password": []byte("secret")}, secret.Data)
+		}
+	}
+
+	_, _, err = readObjects([]string{"-"}, "default", strings.NewReader("kind: Secret\n"))
+	assert.Error(t, err)
+}
+
+func TestConvertObject(t *testing.T) {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("apps/v1")
+	u.SetKind("Deployment")
+	u.SetName("nginx")
+
+	_, _, err := convertObject(u)
+	assert.EqualError(t, err, "unsupported kind apps/v1, Kind=Deployment, the files can hold HelmReleases, "+
+		"Secrets and ConfigMaps")
+
+	u.SetAPIVersion("v1")
+	u.SetKind("ConfigMap")
+	u.Object["data"] = map[string]interface{}{"values.yaml": "replicaCount: 2"}
+
+	hr, obj, err := convertObject(u)
+	assert.NoError(t, err)
+	assert.Nil(t, hr)
+
+	configMap, ok := obj.(*corev1.ConfigMap)
+	if assert.True(t, ok) {
+		assert.Equal(t, map[string]string{"values.yaml": "replicaCount: 2"}, configMap.Data)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	pflag "github.com/spf13/pflag"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"k8s.io/klog"
+
+	// registers the --kubeconfig flag
+	_ "sigs.k8s.io/controller-runtime/pkg/client/config"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const usage = `helmrelease renders, diffs and lints HelmReleases like the operator, without deploying it.
+
+Usage:
+  helmrelease render -f <file>...  print the manifests that the operator installs for the HelmReleases
+  helmrelease diff -f <file>...    diff the manifests against the releases deployed in the cluster
+  helmrelease lint -f <file>...    check the sources, the values and the charts of the HelmReleases
+
+The files hold HelmReleases of the v1 or v2 API, and the Secrets and ConfigMaps that their sources
+reference. "-" reads the standard input. Run "helmrelease <command> --help" for the flags.
+`
+
+// klogFlags registers the klog flags once, Run can be called more than once
+var klogFlags sync.Once
+
+// HelmReleaseCMDOptions for command line flag parsing
+type HelmReleaseCMDOptions struct {
+	Files       []string
+	Namespace   string
+	ChartsDir   string
+	KubeVersion string
+	APIVersions []string
+	Strict      bool
+}
+
+// Run runs the command of the arguments and returns its exit code
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	commands := map[string]func(*HelmReleaseCMDOptions, io.Reader, io.Writer) (int, error){
+		"render": render,
+		"diff":   diff,
+		"lint":   lint,
+	}
+
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	options := &HelmReleaseCMDOptions{Namespace: "default"}
+
+	if err := parseFlags(args[0], args[1:], options, stderr); err != nil {
+		if err == pflag.ErrHelp {
+			return 0
+		}
+
+		fmt.Fprintln(stderr, err)
+
+		return 2
+	}
+
+	if len(options.Files) == 0 {
+		fmt.Fprintln(stderr, "at least one file is required, -f <file>")
+		return 2
+	}
+
+	if options.ChartsDir == "" {
+		dir, err := ioutil.TempDir("", "hr-charts")
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+
+		defer os.RemoveAll(dir)
+
+		options.ChartsDir = dir
+	}
+
+	// the charts are downloaded where the controller downloads them
+	if err := os.Setenv(appv1.ChartsDir, options.ChartsDir); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	code, err := command(options, stdin, stdout)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+	}
+
+	return code
+}
+
+// parseFlags parses the flags of the command into options, the logs are discarded unless --logtostderr is set
+func parseFlags(command string, args []string, options *HelmReleaseCMDOptions, stderr io.Writer) error {
+	flags := pflag.NewFlagSet("helmrelease "+command, pflag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	flags.StringSliceVarP(
+		&options.Files,
+		"filename",
+		"f",
+		options.Files,
+		"The files of the HelmReleases and of the Secrets and ConfigMaps of their sources, - for the standard input.",
+	)
+
+	flags.StringVarP(
+		&options.Namespace,
+		"namespace",
+		"n",
+		options.Namespace,
+		"The namespace of the objects of the files without namespace.",
+	)
+
+	flags.StringVar(
+		&options.ChartsDir,
+		"charts-dir",
+		options.ChartsDir,
+		"The directory where the charts are downloaded and expanded. Defaults to a temporary directory.",
+	)
+
+	if command != "diff" {
+		flags.StringVar(
+			&options.KubeVersion,
+			"kube-version",
+			options.KubeVersion,
+			"The Kubernetes version of .Capabilities.KubeVersion. Defaults to the default version of Helm.",
+		)
+
+		flags.StringSliceVar(
+			&options.APIVersions,
+			"api-versions",
+			options.APIVersions,
+			"The API versions added to .Capabilities.APIVersions.",
+		)
+	}
+
+	if command == "lint" {
+		flags.BoolVar(
+			&options.Strict,
+			"strict",
+			options.Strict,
+			"Fail on the lint warnings and on the values that the charts do not define.",
+		)
+	}
+
+	klogFlags.Do(func() { klog.InitFlags(nil) })
+	klog.SetOutput(ioutil.Discard)
+
+	_ = flag.Set("logtostderr", "false")
+	_ = flag.Set("stderrthreshold", "FATAL")
+
+	flags.AddGoFlagSet(flag.CommandLine)
+
+	return flags.Parse(args)
+}
+
+// capabilities returns the capabilities of the flags, nil for the default capabilities of Helm
+func (o *HelmReleaseCMDOptions) capabilities() (*chartutil.Capabilities, error) {
+	if o.KubeVersion == "" && len(o.APIVersions) == 0 {
+		return nil, nil
+	}
+
+	capabilities := &chartutil.Capabilities{APIVersions: o.APIVersions}
+
+	if o.KubeVersion != "" {
+		kubeVersion, err := chartutil.ParseKubeVersion(o.KubeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --kube-version %s: %w", o.KubeVersion, err)
+		}
+
+		capabilities.KubeVersion = *kubeVersion
+	}
+
+	return capabilities, nil
+}
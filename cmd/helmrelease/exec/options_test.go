@@ -0,0 +1,112 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// chartObjects returns the ConfigMap of the test chart and a HelmRelease that reads it from its sources list
+// after a missing ConfigMap
+func chartObjects(t *testing.T, values string) string {
+	chartZip, err := ioutil.ReadFile("../../../test/helmrepo/subscription-release-test-1-0.1.0.tgz")
+	assert.NoError(t, err)
+
+	return `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: chart
+binaryData:
+  chart.tgz: ` + base64.StdEncoding.EncodeToString(chartZip) + `
+---
+apiVersion: apps.open-cluster-management.io/v1
+kind: HelmRelease
+metadata:
+  name: test
+repo:
+  chartName: subscription-release-test-1
+  sources:
+  - name: missing
+    type: configmap
+    configMap:
+      name: missing
+  - name: chart
+    type: configmap
+    configMap:
+      name: chart
+spec:
+` + values
+}
+
+func writeFile(t *testing.T, dir, content string) string {
+	file := filepath.Join(dir, "objects.yaml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(content), 0600))
+
+	return file
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("/tmp", "helmrelease")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	file := writeFile(t, dir, chartObjects(t, "  enabled: true\n"))
+
+	tests := []struct {
+		name   string
+		args   []string
+		stdin  string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{name: "no command", args: nil, code: 2, stderr: "Usage:"},
+		{name: "help", args: []string{"--help"}, code: 2, stderr: "Usage:"},
+		{name: "unknown command", args: []string{"install"}, code: 2, stderr: `unknown command "install"`},
+		{name: "command help", args: []string{"render", "--help"}, code: 0, stderr: "--filename"},
+		{name: "unknown flag", args: []string{"render", "--strict"}, code: 2, stderr: "unknown flag: --strict"},
+		{name: "no file", args: []string{"render"}, code: 2, stderr: "at least one file is required"},
+		{name: "missing file", args: []string{"render", "-f", filepath.Join(dir, "missing.yaml")}, code: 1,
+			stderr: "no such file"},
+		{name: "no HelmRelease", args: []string{"render", "-f", "-"}, stdin: "apiVersion: v1\nkind: ConfigMap\n" +
+			"metadata:\n  name: values\n", code: 1, stderr: "no HelmRelease in the files"},
+		{name: "render failover", args: []string{"render", "-f", file, "--charts-dir", dir}, code: 0,
+			stdout: "# HelmRelease: default/test\n---\n# Source: subscription-release-test-1/templates/"},
+		{name: "render stdin", args: []string{"render", "-n", "apps", "-f", "-", "--charts-dir", dir},
+			stdin: chartObjects(t, ""), code: 0, stdout: "# HelmRelease: apps/test\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			code := Run(tt.args, strings.NewReader(tt.stdin), stdout, stderr)
+
+			assert.Equal(t, tt.code, code, stderr.String())
+			assert.Contains(t, stdout.String(), tt.stdout)
+			assert.Contains(t, stderr.String(), tt.stderr)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	rpb "helm.sh/helm/v3/pkg/release"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/stolostron/multicloud-operators-subscription-release/pkg/controller/helmrelease"
+)
+
+// render prints the manifests and the hooks of the HelmReleases, like helm template
+func render(o *HelmReleaseCMDOptions, stdin io.Reader, stdout io.Writer) (int, error) {
+	helmReleases, c, err := offlineObjects(o, stdin)
+	if err != nil {
+		return 1, err
+	}
+
+	capabilities, err := o.capabilities()
+	if err != nil {
+		return 1, err
+	}
+
+	for _, hr := range helmReleases {
+		loaded, err := helmrelease.LoadChart(c, hr)
+		if err != nil {
+			return 1, fmt.Errorf("HelmRelease %s: %w", nsn(hr), err)
+		}
+
+		rel, err := loaded.Render(hr, capabilities)
+		if err != nil {
+			return 1, fmt.Errorf("HelmRelease %s: %w", nsn(hr), err)
+		}
+
+		fmt.Fprintf(stdout, "# HelmRelease: %s\n", nsn(hr))
+		writeRelease(stdout, rel)
+	}
+
+	return 0, nil
+}
+
+// offlineObjects reads the HelmReleases of the files, and a client of the Secrets and ConfigMaps of the files
+func offlineObjects(o *HelmReleaseCMDOptions, stdin io.Reader) ([]*appv1.HelmRelease, client.Client, error) {
+	helmReleases, objects, err := readObjects(o.Files, o.Namespace, stdin)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return helmReleases, newOfflineClient(objects), nil
+}
+
+// writeRelease writes the manifest of the release and then its hooks
+func writeRelease(w io.Writer, rel *rpb.Release) {
+	if manifest := strings.TrimSpace(rel.Manifest); manifest != "" {
+		fmt.Fprintln(w, manifest)
+	}
+
+	for _, hook := range rel.Hooks {
+		fmt.Fprintf(w, "---\n# Source: %s\n%s\n", hook.Path, strings.TrimSpace(hook.Manifest))
+	}
+}
+
+func nsn(hr *appv1.HelmRelease) string {
+	return hr.GetNamespace() + "/" + hr.GetName()
+}
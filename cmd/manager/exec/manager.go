@@ -15,49 +15,91 @@
 package exec
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/stolostron/multicloud-operators-subscription-release/pkg/apis"
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
 	"github.com/stolostron/multicloud-operators-subscription-release/pkg/controller"
+	"github.com/stolostron/multicloud-operators-subscription-release/pkg/utils"
 
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
 )
 
-// Change below variables to serve metrics on different host or port.
+// Change below variables to serve the webhooks on a different port.
 var (
-	metricsHost             = "0.0.0.0"
-	metricsPort         int = 8382
 	operatorMetricsPort int = 8685
 )
 
 // RunManager starts the actual manager
 func RunManager() {
-	enableLeaderElection := false
-	if _, err := rest.InClusterConfig(); err == nil {
-		klog.Info("LeaderElection enabled as running in a cluster")
-		enableLeaderElection = true
-	} else {
-		klog.Info("LeaderElection disabled as not running in a cluster")
+	if errs := validation.IsValidLabelValue(options.Shard); len(errs) > 0 {
+		klog.Error("Invalid shard ", options.Shard, ": ", strings.Join(errs, ", "))
+		os.Exit(1)
 	}
 
-	// Create a new Cmd to provide shared dependencies and start components
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		MetricsBindAddress:      fmt.Sprintf("%s:%d", metricsHost, metricsPort),
+	leaderElectionID := shardLeaderElectionID(options.LeaderElectionID, options.Shard,
+		pflag.CommandLine.Changed("leader-election-id"))
+
+	klog.Info("Shard: ", options.Shard, " LeaderElection: ", options.LeaderElection, " lease: ",
+		options.LeaderElectionNamespace, "/", leaderElectionID)
+
+	if err := os.Setenv(appv1.ChartsDir, options.ChartsDir); err != nil {
+		klog.Error(err, "")
+		os.Exit(1)
+	}
+
+	if err := os.Setenv(appv1.TokenFilesDir, options.TokenFilesDir); err != nil {
+		klog.Error(err, "")
+		os.Exit(1)
+	}
+
+	mgrOptions := ctrl.Options{
+		MetricsBindAddress:      options.MetricsAddr,
+		HealthProbeBindAddress:  options.HealthProbeAddr,
 		Port:                    operatorMetricsPort,
-		LeaderElection:          enableLeaderElection,
-		LeaderElectionID:        "multicloud-operators-subscription-release-leader.open-cluster-management.io",
-		LeaderElectionNamespace: "kube-system",
-	})
+		LeaderElection:          options.LeaderElection,
+		LeaderElectionID:        leaderElectionID,
+		LeaderElectionNamespace: options.LeaderElectionNamespace,
+		CertDir:                 options.WebhookCertDir,
+		// the Secrets and ConfigMaps of the sources are read on demand, not cached for the whole cluster
+		ClientDisableCacheFor: []client.Object{&corev1.Secret{}, &corev1.ConfigMap{}},
+	}
+
+	setCache(&mgrOptions, options.WatchNamespaces, options.Shard)
+
+	// Create a new Cmd to provide shared dependencies and start components
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), mgrOptions)
 
 	if err != nil {
 		klog.Error(err, "")
 		os.Exit(1)
 	}
 
+	if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		klog.Error(err, "")
+		os.Exit(1)
+	}
+
+	if err := mgr.AddReadyzCheck("cache-sync", cacheSynced(mgr.GetCache())); err != nil {
+		klog.Error(err, "")
+		os.Exit(1)
+	}
+
 	klog.Info("Registering Components.")
 
 	// Setup Scheme for all resources
@@ -67,11 +109,21 @@ func RunManager() {
 	}
 
 	// Setup all Controllers
+	controller.HelmReleaseOptions = options.ControllerOptions
+
 	if err := controller.AddToManager(mgr); err != nil {
 		klog.Error(err, "")
 		os.Exit(1)
 	}
 
+	// Serve the conversion between the HelmRelease versions, v1 is the hub
+	if options.EnableWebhooks {
+		if err := ctrl.NewWebhookManagedBy(mgr).For(&appv1.HelmRelease{}).Complete(); err != nil {
+			klog.Error(err, "")
+			os.Exit(1)
+		}
+	}
+
 	sig := signals.SetupSignalHandler()
 
 	klog.Info("Starting the Cmd.")
@@ -82,3 +134,79 @@ func RunManager() {
 		os.Exit(1)
 	}
 }
+
+// setCache restricts the cache of the manager to the namespaces, plus the namespace of the operator that
+// holds the policies shared by all the HelmReleases, and to the HelmReleases of the shard. No namespace
+// watches all the namespaces and no shard all the HelmReleases.
+func setCache(mgrOptions *ctrl.Options, namespaces []string, shard string) {
+	if len(namespaces) > 0 {
+		if ns := os.Getenv("POD_NAMESPACE"); ns != "" && !contains(namespaces, ns) {
+			namespaces = append(namespaces, ns)
+		}
+
+		klog.Info("Watching the namespaces ", namespaces)
+	}
+
+	if len(namespaces) == 1 {
+		mgrOptions.Namespace = namespaces[0]
+	}
+
+	selectors := cache.SelectorsByObject{}
+	if shard != "" {
+		selectors[&appv1.HelmRelease{}] = cache.ObjectSelector{
+			Label: labels.SelectorFromSet(labels.Set{appv1.ShardLabel: shard}),
+		}
+	}
+
+	mgrOptions.NewCache = func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		opts.SelectorsByObject = selectors
+
+		if len(namespaces) > 1 {
+			return cache.MultiNamespacedCacheBuilder(namespaces)(config, opts)
+		}
+
+		return cache.New(config, opts)
+	}
+}
+
+// shardLeaderElectionID returns the lease name of the shard, so that a leader is elected among the replicas of
+// each shard. A lease name set explicitly is kept as is.
+func shardLeaderElectionID(id, shard string, explicit bool) string {
+	if shard == "" || explicit {
+		return id
+	}
+
+	// a label value can have upper case letters and underscores that a lease name can not have
+	shard = strings.ReplaceAll(strings.ToLower(shard), "_", "-")
+
+	if name, domain, found := utils.CutString(id, "."); found {
+		return name + "-" + shard + "." + domain
+	}
+
+	return id + "-" + shard
+}
+
+// cacheSynced is ready once the informers of the controller have synced, before that the HelmReleases are not
+// reconciled yet
+func cacheSynced(c cache.Cache) healthz.Checker {
+	return func(req *http.Request) error {
+		ctx, cancel := context.WithTimeout(req.Context(), time.Second)
+		defer cancel()
+
+		if !c.WaitForCacheSync(ctx) {
+			return errors.New("the informer caches are not synced")
+		}
+
+		return nil
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
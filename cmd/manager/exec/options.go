@@ -15,16 +15,48 @@
 package exec
 
 import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
 	pflag "github.com/spf13/pflag"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	"github.com/stolostron/multicloud-operators-subscription-release/pkg/controller/helmrelease"
 )
 
 // SubscriptionReleaseCMDOptions for command line flag parsing
 type SubscriptionReleaseCMDOptions struct {
-	MetricsAddr string
+	MetricsAddr             string
+	HealthProbeAddr         string
+	LeaderElection          bool
+	LeaderElectionNamespace string
+	LeaderElectionID        string
+	ChartsDir               string
+	TokenFilesDir           string
+	WatchNamespaces         []string
+	Shard                   string
+	EnableWebhooks          bool
+	WebhookCertDir          string
+	ControllerOptions       helmrelease.Options
 }
 
 var options = SubscriptionReleaseCMDOptions{
-	MetricsAddr: "",
+	MetricsAddr:             "0.0.0.0:8382",
+	HealthProbeAddr:         "0.0.0.0:8383",
+	LeaderElection:          false,
+	LeaderElectionNamespace: "kube-system",
+	LeaderElectionID:        "multicloud-operators-subscription-release-leader.open-cluster-management.io",
+	ChartsDir:               "/tmp/hr-charts",
+	TokenFilesDir:           "",
+	WatchNamespaces:         []string{},
+	Shard:                   "",
+	EnableWebhooks:          false,
+	WebhookCertDir:          "",
+	ControllerOptions:       helmrelease.DefaultOptions(),
 }
 
 // ProcessFlags parses command line parameters into options
@@ -35,6 +67,203 @@ func ProcessFlags() {
 		&options.MetricsAddr,
 		"metrics-addr",
 		options.MetricsAddr,
-		"The address the metric endpoint binds to.",
+		"The address the metric endpoint binds to. 0 disables the metrics.",
+	)
+
+	flag.StringVar(
+		&options.HealthProbeAddr,
+		"health-probe-addr",
+		options.HealthProbeAddr,
+		"The address the /healthz and /readyz endpoints bind to. 0 disables the probes.",
+	)
+
+	// the leader election is enabled by default when running in a cluster
+	if _, err := rest.InClusterConfig(); err == nil {
+		options.LeaderElection = true
+	}
+
+	flag.BoolVar(
+		&options.LeaderElection,
+		"leader-elect",
+		options.LeaderElection,
+		"Elect a leader among the replicas of the operator. Defaults to true when running in a cluster.",
+	)
+
+	flag.StringVar(
+		&options.LeaderElectionNamespace,
+		"leader-election-namespace",
+		options.LeaderElectionNamespace,
+		"The namespace of the leader election lease.",
+	)
+
+	flag.StringVar(
+		&options.LeaderElectionID,
+		"leader-election-id",
+		options.LeaderElectionID,
+		"The name of the leader election lease.",
+	)
+
+	flag.StringVar(
+		&options.ChartsDir,
+		"charts-dir",
+		envString(appv1.ChartsDir, options.ChartsDir),
+		"The directory where the charts are downloaded and expanded. Env "+appv1.ChartsDir+".",
+	)
+
+	flag.StringVar(
+		&options.TokenFilesDir,
+		"token-files-dir",
+		envString(appv1.TokenFilesDir, options.TokenFilesDir),
+		"The directory of the token files that the tokenFile key of the git sources can name, for example a "+
+			"projected service account token volume. Defaults to no token files. Env "+appv1.TokenFilesDir+".",
+	)
+
+	flag.StringSliceVar(
+		&options.WatchNamespaces,
+		"watch-namespaces",
+		envList("WATCH_NAMESPACE", options.WatchNamespaces),
+		"The comma separated namespaces of the HelmReleases to reconcile. Defaults to all the namespaces. "+
+			"Env WATCH_NAMESPACE.",
+	)
+
+	flag.StringVar(
+		&options.Shard,
+		"shard",
+		envString("SHARD", options.Shard),
+		"Reconcile only the HelmReleases labeled "+appv1.ShardLabel+" with this shard, and elect a leader among "+
+			"the replicas of the same shard only. Defaults to all the HelmReleases. Env SHARD.",
+	)
+
+	flag.BoolVar(
+		&options.EnableWebhooks,
+		"enable-webhooks",
+		options.EnableWebhooks,
+		"Serve the HelmRelease conversion webhook. Required to serve the v2 API.",
+	)
+
+	flag.StringVar(
+		&options.WebhookCertDir,
+		"webhook-cert-dir",
+		options.WebhookCertDir,
+		"The directory that contains the webhook server tls.crt and tls.key. Defaults to <temp-dir>/k8s-webhook-server/serving-certs.",
+	)
+
+	controllerOptions := &options.ControllerOptions
+
+	flag.IntVar(
+		&controllerOptions.MaxConcurrentReconciles,
+		"max-concurrent-reconciles",
+		envInt("MAX_CONCURRENT_RECONCILES", controllerOptions.MaxConcurrentReconciles),
+		"The number of HelmReleases reconciled in parallel. Env MAX_CONCURRENT_RECONCILES.",
 	)
+
+	flag.DurationVar(
+		&controllerOptions.RetryBaseDelay,
+		"retry-base-delay",
+		envDuration("RETRY_BASE_DELAY", controllerOptions.RetryBaseDelay),
+		"The first delay of the per-HelmRelease exponential backoff of the failed reconciles. Env RETRY_BASE_DELAY.",
+	)
+
+	flag.DurationVar(
+		&controllerOptions.RetryMaxDelay,
+		"retry-max-delay",
+		envDuration("RETRY_MAX_DELAY", controllerOptions.RetryMaxDelay),
+		"The maximum delay of the per-HelmRelease exponential backoff of the failed reconciles. Env RETRY_MAX_DELAY.",
+	)
+
+	flag.Float64Var(
+		&controllerOptions.QPS,
+		"workqueue-qps",
+		envFloat("WORKQUEUE_QPS", controllerOptions.QPS),
+		"The reconciles per second allowed by the token bucket shared by all the HelmReleases. Env WORKQUEUE_QPS.",
+	)
+
+	flag.IntVar(
+		&controllerOptions.Burst,
+		"workqueue-burst",
+		envInt("WORKQUEUE_BURST", controllerOptions.Burst),
+		"The burst of the token bucket shared by all the HelmReleases. Env WORKQUEUE_BURST.",
+	)
+
+	flag.DurationVar(
+		&controllerOptions.DownloadRetryInterval,
+		"download-retry-interval",
+		envDuration("DOWNLOAD_RETRY_INTERVAL", controllerOptions.DownloadRetryInterval),
+		"The requeue interval of a HelmRelease whose chart failed to download. Env DOWNLOAD_RETRY_INTERVAL.",
+	)
+
+	flag.DurationVar(
+		&controllerOptions.ReleaseRetryInterval,
+		"release-retry-interval",
+		envDuration("RELEASE_RETRY_INTERVAL", controllerOptions.ReleaseRetryInterval),
+		"The requeue interval of a HelmRelease whose release failed. Env RELEASE_RETRY_INTERVAL.",
+	)
+}
+
+// envString returns the environment variable, or the default when it is not set
+func envString(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+
+	return def
+}
+
+// envList returns the comma separated values of the environment variable, or the default when it is not set
+func envList(name string, def []string) []string {
+	list := []string{}
+
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+
+	if len(list) == 0 {
+		return def
+	}
+
+	return list
+}
+
+// envInt returns the integer of the environment variable, or the default when it is not set or invalid
+func envInt(name string, def int) int {
+	if v := os.Getenv(name); v != "" {
+		i, err := strconv.Atoi(v)
+		if err == nil {
+			return i
+		}
+
+		klog.Warning("Ignoring invalid ", name, " ", err)
+	}
+
+	return def
+}
+
+// envFloat returns the number of the environment variable, or the default when it is not set or invalid
+func envFloat(name string, def float64) float64 {
+	if v := os.Getenv(name); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err == nil {
+			return f
+		}
+
+		klog.Warning("Ignoring invalid ", name, " ", err)
+	}
+
+	return def
+}
+
+// envDuration returns the duration of the environment variable, or the default when it is not set or invalid
+func envDuration(name string, def time.Duration) time.Duration {
+	if v := os.Getenv(name); v != "" {
+		d, err := time.ParseDuration(v)
+		if err == nil {
+			return d
+		}
+
+		klog.Warning("Ignoring invalid ", name, " ", err)
+	}
+
+	return def
 }
@@ -19,7 +19,6 @@ package v1
 import (
 	"encoding/json"
 	"fmt"
-	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -33,6 +32,12 @@ import (
 //ChartsDir env variable name which contains the directory where the charts are installed
 const ChartsDir = "CHARTS_DIR"
 
+// TokenFilesDir env variable name which contains the directory of the token files that the git sources can name
+const TokenFilesDir = "TOKEN_FILES_DIR"
+
+// ShardLabel is the label of the HelmReleases that assigns them to the operator instance started with the same shard
+const ShardLabel = "apps.open-cluster-management.io/helmrelease-shard"
+
 //SourceTypeEnum types of sources
 type SourceTypeEnum string
 
@@ -43,6 +48,12 @@ const (
 	GitHubSourceType SourceTypeEnum = "github"
 	// GitSourceType git source type
 	GitSourceType SourceTypeEnum = "git"
+	// S3SourceType S3-compatible object storage source type
+	S3SourceType SourceTypeEnum = "s3"
+	// ConfigMapSourceType ConfigMap or Secret source type
+	ConfigMapSourceType SourceTypeEnum = "configmap"
+	// OCISourceType OCI registry source type
+	OCISourceType SourceTypeEnum = "oci"
 )
 
 //GitHub provides the parameters to access the helm-chart located in a github repo
@@ -50,6 +61,8 @@ type GitHub struct {
 	Urls      []string `json:"urls,omitempty"`
 	ChartPath string   `json:"chartPath,omitempty"`
 	Branch    string   `json:"branch,omitempty"`
+	// DisableSubmodules disables the recursive checkout of the submodules
+	DisableSubmodules bool `json:"disableSubmodules,omitempty"`
 }
 
 //Git provides the parameters to access the helm-chart located in a git repo
@@ -57,58 +70,127 @@ type Git struct {
 	Urls      []string `json:"urls,omitempty"`
 	ChartPath string   `json:"chartPath,omitempty"`
 	Branch    string   `json:"branch,omitempty"`
+	// DisableSubmodules disables the recursive checkout of the submodules
+	DisableSubmodules bool `json:"disableSubmodules,omitempty"`
 }
 
 //HelmRepo provides the urls to retrieve the helm-chart
 type HelmRepo struct {
 	Urls []string `json:"urls,omitempty"`
+	// Verify refuses the chart unless its provenance file, downloaded from the url of the chart with a .prov
+	// extension, is signed by a key of the keyring
+	Verify bool `json:"verify,omitempty"`
+	// KeyringSecretRef is the secret holding the public keyring in its keyring key, it is read in the
+	// namespace of the HelmRelease when the namespace is empty
+	KeyringSecretRef *corev1.ObjectReference `json:"keyringSecretRef,omitempty"`
+	// Cosign refuses the chart unless its detached signature, downloaded from the url of the chart with a
+	// .sig extension, is signed by the cosign public key
+	Cosign *CosignVerification `json:"cosign,omitempty"`
+}
+
+//OCI provides the repository of the helm-chart stored as an OCI artifact in a registry
+type OCI struct {
+	// Repository is the repository of the chart, for example oci://registry.example.com/charts/nginx-ingress
+	Repository string `json:"repository,omitempty"`
+	// Tag defaults to the version of the chart
+	Tag string `json:"tag,omitempty"`
+	// Cosign refuses the chart unless the registry holds a cosign signature of its manifest signed by the
+	// cosign public key
+	Cosign *CosignVerification `json:"cosign,omitempty"`
+}
+
+// CosignVerification provides the cosign public key that signs the chart. The PEM encoded key is read from
+// the cosign.pub key of the secret, or of the config map when there is no secret, in the namespace of the
+// HelmRelease when the namespace is empty.
+type CosignVerification struct {
+	SecretRef    *corev1.ObjectReference `json:"secretRef,omitempty"`
+	ConfigMapRef *corev1.ObjectReference `json:"configMapRef,omitempty"`
+}
+
+//S3 provides the parameters to access the helm-chart located in an S3-compatible bucket.
+//The access key is read from the accessKeyID, secretAccessKey and optional sessionToken keys of the secret.
+type S3 struct {
+	// Endpoint is the url of the object storage, for example https://minio.example.com:9000
+	Endpoint string `json:"endpoint,omitempty"`
+	Bucket   string `json:"bucket,omitempty"`
+	// Region defaults to us-east-1
+	Region string `json:"region,omitempty"`
+	// KeyPrefix is prepended to the keys of the index.yaml and the chart archives
+	KeyPrefix string `json:"keyPrefix,omitempty"`
+	// Key is the key of the chart archive. When empty the archive is looked up in the index.yaml under
+	// KeyPrefix and then defaults to <KeyPrefix><chartName>-<version>.tgz
+	Key string `json:"key,omitempty"`
+}
+
+//ConfigMapSource provides the ConfigMap or Secret that holds the helm-chart archive, in the namespace of the
+//HelmRelease. The chart is fetched again when the object changes.
+type ConfigMapSource struct {
+	// Kind is ConfigMap (default) or Secret
+	// +kubebuilder:validation:Enum=ConfigMap;Secret
+	Kind string `json:"kind,omitempty"`
+	Name string `json:"name,omitempty"`
+	// Keys are the keys of the parts of the chart archive, concatenated in order. When empty all the
+	// binaryData keys of the ConfigMap, or the data keys of the Secret, are concatenated in lexical order.
+	Keys []string `json:"keys,omitempty"`
 }
 
 //Source holds the different types of repository
 type Source struct {
-	SourceType SourceTypeEnum `json:"type,omitempty"`
-	GitHub     *GitHub        `json:"github,omitempty"`
-	Git        *Git           `json:"git,omitempty"`
-	HelmRepo   *HelmRepo      `json:"helmRepo,omitempty"`
+	SourceType SourceTypeEnum   `json:"type,omitempty"`
+	GitHub     *GitHub          `json:"github,omitempty"`
+	Git        *Git             `json:"git,omitempty"`
+	HelmRepo   *HelmRepo        `json:"helmRepo,omitempty"`
+	S3         *S3              `json:"s3,omitempty"`
+	ConfigMap  *ConfigMapSource `json:"configMap,omitempty"`
+	OCI        *OCI             `json:"oci,omitempty"`
 }
 
 //AltSource holds the alternative source
 type AltSource struct {
+	// Name identifies the source in the status, it defaults to its position in the repo
+	Name               string                  `json:"name,omitempty"`
 	SourceType         SourceTypeEnum          `json:"type,omitempty"`
 	GitHub             *GitHub                 `json:"github,omitempty"`
 	Git                *Git                    `json:"git,omitempty"`
 	HelmRepo           *HelmRepo               `json:"helmRepo,omitempty"`
+	S3                 *S3                     `json:"s3,omitempty"`
+	ConfigMap          *ConfigMapSource        `json:"configMap,omitempty"`
+	OCI                *OCI                    `json:"oci,omitempty"`
 	SecretRef          *corev1.ObjectReference `json:"secretRef,omitempty"`
 	ConfigMapRef       *corev1.ObjectReference `json:"configMapRef,omitempty"`
 	InsecureSkipVerify bool                    `json:"insecureSkipVerify,omitempty"`
 }
 
+// String returns the type and the parameters of the source. The chart sources give a shorter description.
 func (s Source) String() string {
-	switch strings.ToLower(string(s.SourceType)) {
-	case string(HelmRepoSourceType):
-		return fmt.Sprintf("%v", s.HelmRepo.Urls)
-	case string(GitHubSourceType):
-		return fmt.Sprintf("%v|%s|%s", s.GitHub.Urls, s.GitHub.Branch, s.GitHub.ChartPath)
-	case string(GitSourceType):
-		return fmt.Sprintf("%v|%s|%s", s.Git.Urls, s.Git.Branch, s.Git.ChartPath)
-	default:
-		return fmt.Sprintf("SourceType %s not supported", s.SourceType)
+	params := s
+	params.SourceType = ""
+
+	b, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Sprintf("%s %v", s.SourceType, err)
 	}
+
+	return fmt.Sprintf("%s %s", s.SourceType, b)
 }
 
-func (s AltSource) String() string {
-	switch strings.ToLower(string(s.SourceType)) {
-	case string(HelmRepoSourceType):
-		return fmt.Sprintf("%v", s.HelmRepo.Urls)
-	case string(GitHubSourceType):
-		return fmt.Sprintf("%v|%s|%s", s.GitHub.Urls, s.GitHub.Branch, s.GitHub.ChartPath)
-	case string(GitSourceType):
-		return fmt.Sprintf("%v|%s|%s", s.Git.Urls, s.Git.Branch, s.Git.ChartPath)
-	default:
-		return fmt.Sprintf("SourceType %s not supported", s.SourceType)
+// ToSource returns the Source defined by the alternative source
+func (s AltSource) ToSource() *Source {
+	return &Source{
+		SourceType: s.SourceType,
+		GitHub:     s.GitHub,
+		Git:        s.Git,
+		HelmRepo:   s.HelmRepo,
+		S3:         s.S3,
+		ConfigMap:  s.ConfigMap,
+		OCI:        s.OCI,
 	}
 }
 
+func (s AltSource) String() string {
+	return s.ToSource().String()
+}
+
 func (repo HelmReleaseRepo) Clone() HelmReleaseRepo {
 	return HelmReleaseRepo{
 		ChartName:          repo.ChartName,
@@ -119,27 +201,87 @@ func (repo HelmReleaseRepo) Clone() HelmReleaseRepo {
 		ConfigMapRef:       repo.ConfigMapRef,
 		InsecureSkipVerify: repo.InsecureSkipVerify,
 		Source:             repo.Source,
+		Sources:            repo.Sources,
+		FailoverPolicy:     repo.FailoverPolicy,
 	}
 }
 
 func (repo HelmReleaseRepo) AltSourceToSource() HelmReleaseRepo {
-	return HelmReleaseRepo{
-		ChartName:          repo.ChartName,
-		Version:            repo.Version,
-		Digest:             repo.Digest,
-		AltSource:          repo.AltSource,
-		SecretRef:          repo.AltSource.SecretRef,
-		ConfigMapRef:       repo.AltSource.ConfigMapRef,
-		InsecureSkipVerify: repo.AltSource.InsecureSkipVerify,
-		Source: &Source{
-			SourceType: repo.AltSource.SourceType,
-			GitHub:     repo.AltSource.GitHub,
-			Git:        repo.AltSource.Git,
-			HelmRepo:   repo.AltSource.HelmRepo,
-		},
+	return repo.WithSource(*repo.AltSource)
+}
+
+// WithSource returns a copy of the repo that downloads the chart from source with its credentials and TLS settings
+func (repo HelmReleaseRepo) WithSource(source AltSource) HelmReleaseRepo {
+	clone := repo.Clone()
+	clone.Source = source.ToSource()
+	clone.SecretRef = source.SecretRef
+	clone.ConfigMapRef = source.ConfigMapRef
+	clone.InsecureSkipVerify = source.InsecureSkipVerify
+
+	return clone
+}
+
+// CandidateSources returns the sources the chart can be downloaded from, in order: Source with the
+// credentials of the repo, AltSource then Sources. The sources without name are named after their position.
+func (repo HelmReleaseRepo) CandidateSources() []AltSource {
+	candidates := []AltSource{}
+
+	if repo.Source != nil {
+		candidates = append(candidates, AltSource{
+			Name:               "source",
+			SourceType:         repo.Source.SourceType,
+			GitHub:             repo.Source.GitHub,
+			Git:                repo.Source.Git,
+			HelmRepo:           repo.Source.HelmRepo,
+			S3:                 repo.Source.S3,
+			ConfigMap:          repo.Source.ConfigMap,
+			OCI:                repo.Source.OCI,
+			SecretRef:          repo.SecretRef,
+			ConfigMapRef:       repo.ConfigMapRef,
+			InsecureSkipVerify: repo.InsecureSkipVerify,
+		})
+	}
+
+	if repo.AltSource != nil {
+		altSource := *repo.AltSource
+		if altSource.Name == "" {
+			altSource.Name = "altSource"
+		}
+
+		candidates = append(candidates, altSource)
+	}
+
+	for i, source := range repo.Sources {
+		if source.Name == "" {
+			source.Name = fmt.Sprintf("sources[%d]", i)
+		}
+
+		candidates = append(candidates, source)
+	}
+
+	return candidates
+}
+
+// GetFailoverPolicy returns the failover policy, defaulting to FirstSuccess
+func (repo HelmReleaseRepo) GetFailoverPolicy() FailoverPolicy {
+	if repo.FailoverPolicy == "" {
+		return FailoverPolicyFirstSuccess
 	}
+
+	return repo.FailoverPolicy
 }
 
+// FailoverPolicy defines the order the candidate sources are tried in
+type FailoverPolicy string
+
+const (
+	// FailoverPolicyFirstSuccess tries the sources in order on every reconcile and uses the first one that serves the chart
+	FailoverPolicyFirstSuccess FailoverPolicy = "FirstSuccess"
+	// FailoverPolicyPreferred remembers the sources that failed and tries them after the healthy ones until
+	// they are due for a retry, so the chart moves back to the preferred (first) source once it recovers
+	FailoverPolicyPreferred FailoverPolicy = "Preferred"
+)
+
 // HelmReleaseRepo defines the repository of HelmRelease
 // +k8s:openapi-gen=true
 type HelmReleaseRepo struct {
@@ -162,6 +304,112 @@ type HelmReleaseRepo struct {
 	ConfigMapRef *corev1.ObjectReference `json:"configMapRef,omitempty"`
 	// InsecureSkipVerify is used to skip repo server's TLS certificate verification
 	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+	// Sources are more sources tried after Source and AltSource, each with its own credentials and TLS settings
+	Sources []AltSource `json:"sources,omitempty"`
+	// FailoverPolicy is FirstSuccess (default) or Preferred
+	// +kubebuilder:validation:Enum=FirstSuccess;Preferred
+	FailoverPolicy FailoverPolicy `json:"failoverPolicy,omitempty"`
+}
+
+// HelmReleaseInstall defines how the HelmRelease takes over releases and objects it did not create
+// +k8s:openapi-gen=true
+type HelmReleaseInstall struct {
+	// AdoptRelease takes over an existing Helm release of the same chart with the same name, for example
+	// one installed with the helm CLI. A release of a chart with a different name is not adopted.
+	AdoptRelease bool `json:"adoptRelease,omitempty"`
+	// TakeOwnership adopts pre-existing objects rendered by the chart by adding the Helm ownership
+	// labels/annotations and the HelmRelease owner reference to them before the install
+	TakeOwnership bool `json:"takeOwnership,omitempty"`
+	// Replace re-uses the name of a deleted or failed release and adopts pre-existing objects like TakeOwnership
+	Replace bool `json:"replace,omitempty"`
+}
+
+// AdoptsObjects returns true if pre-existing objects must be adopted before installing or upgrading
+func (i *HelmReleaseInstall) AdoptsObjects() bool {
+	return i != nil && (i.TakeOwnership || i.Replace)
+}
+
+// AdoptsRelease returns true if an existing Helm release must be taken over
+func (i *HelmReleaseInstall) AdoptsRelease() bool {
+	return i != nil && i.AdoptRelease
+}
+
+// HelmReleaseUpgrade defines how the release is upgraded
+// +k8s:openapi-gen=true
+type HelmReleaseUpgrade struct {
+	// ServerDryRun sends the manifests of the upgrade to the API server with dryRun=All before upgrading.
+	// The schema and admission errors are reported in the status without upgrading the release.
+	ServerDryRun bool `json:"serverDryRun,omitempty"`
+}
+
+// ServerDryRuns returns true if the manifests of an upgrade must be validated by the API server first
+func (u *HelmReleaseUpgrade) ServerDryRuns() bool {
+	return u != nil && u.ServerDryRun
+}
+
+// HelmReleaseValidation defines how the values of the HelmRelease are validated against the chart
+// +k8s:openapi-gen=true
+type HelmReleaseValidation struct {
+	// RejectUnknownValues refuses the values that the chart defines neither in its values.yaml nor in its
+	// values.schema.json, instead of ignoring them
+	RejectUnknownValues bool `json:"rejectUnknownValues,omitempty"`
+}
+
+// RejectsUnknownValues returns true if the values unknown to the chart must be refused
+func (v *HelmReleaseValidation) RejectsUnknownValues() bool {
+	return v != nil && v.RejectUnknownValues
+}
+
+// DeletionPolicy defines what happens to the release when the HelmRelease is deleted
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete uninstalls the release and waits for its resources to be deleted
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan removes the owner references and the Helm release records but leaves the resources running
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+	// DeletionPolicyKeepReleaseRecord removes the owner references but keeps the Helm release records
+	// and the resources, so the release can be adopted by another HelmRelease
+	DeletionPolicyKeepReleaseRecord DeletionPolicy = "KeepReleaseRecord"
+)
+
+// HelmReleaseUninstall defines how the release is removed when the HelmRelease is deleted
+// +k8s:openapi-gen=true
+type HelmReleaseUninstall struct {
+	// DeletionPolicy is one of Delete (default), Orphan or KeepReleaseRecord
+	// +kubebuilder:validation:Enum=Delete;Orphan;KeepReleaseRecord
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// Timeout is the time to wait for the Helm uninstall, including the hooks and the wait for deletion
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// Wait waits for all the release resources to be deleted before the Helm uninstall returns
+	Wait bool `json:"wait,omitempty"`
+	// KeepHistory keeps the Helm release records marked as uninstalled
+	KeepHistory bool `json:"keepHistory,omitempty"`
+	// ForceAfter is the time after the deletion request when the finalizer is removed even if some
+	// release resources are not deleted yet or can not be checked. The leftover and unchecked resources are
+	// reported in an event.
+	ForceAfter *metav1.Duration `json:"forceAfter,omitempty"`
+}
+
+// GetDeletionPolicy returns the deletion policy, defaulting to Delete
+func (u *HelmReleaseUninstall) GetDeletionPolicy() DeletionPolicy {
+	if u == nil || u.DeletionPolicy == "" {
+		return DeletionPolicyDelete
+	}
+
+	return u.DeletionPolicy
+}
+
+// ForceDeadline returns the time after which the finalizer is removed regardless of the leftover
+// resources, or nil if the uninstall never forces the finalization
+func (u *HelmReleaseUninstall) ForceDeadline(deletionTimestamp *metav1.Time) *metav1.Time {
+	if u == nil || u.ForceAfter == nil || deletionTimestamp == nil {
+		return nil
+	}
+
+	deadline := metav1.NewTime(deletionTimestamp.Add(u.ForceAfter.Duration))
+
+	return &deadline
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -170,12 +418,25 @@ type HelmReleaseRepo struct {
 // +k8s:openapi-gen=true
 // +kubebuilder:resource:scope=Namespaced
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Chart",type=string,JSONPath=`.status.deployedRelease.chartName`
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.deployedRelease.chartVersion`
+// +kubebuilder:printcolumn:name="Revision",type=integer,JSONPath=`.status.deployedRelease.revision`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 type HelmRelease struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
 	Repo HelmReleaseRepo `json:"repo,omitempty"`
 
+	Install *HelmReleaseInstall `json:"install,omitempty"`
+
+	Upgrade *HelmReleaseUpgrade `json:"upgrade,omitempty"`
+
+	Validation *HelmReleaseValidation `json:"validation,omitempty"`
+
+	Uninstall *HelmReleaseUninstall `json:"uninstall,omitempty"`
+
 	Spec   HelmAppSpec   `json:"spec,omitempty"`
 	Status HelmAppStatus `json:"status,omitempty"`
 }
@@ -208,11 +469,16 @@ type HelmAppCondition struct {
 	Message string                 `json:"message,omitempty"`
 
 	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	ObservedGeneration int64       `json:"observedGeneration,omitempty"`
 }
 
 type HelmAppRelease struct {
-	Name     string `json:"name,omitempty"`
-	Manifest string `json:"manifest,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Manifest     string `json:"manifest,omitempty"`
+	ChartName    string `json:"chartName,omitempty"`
+	ChartVersion string `json:"chartVersion,omitempty"`
+	AppVersion   string `json:"appVersion,omitempty"`
+	Revision     int    `json:"revision,omitempty"`
 }
 
 const (
@@ -220,6 +486,14 @@ const (
 	ConditionDeployed       HelmAppConditionType = "Deployed"
 	ConditionReleaseFailed  HelmAppConditionType = "ReleaseFailed"
 	ConditionIrreconcilable HelmAppConditionType = "Irreconcilable"
+	// ConditionReady summarizes the other conditions, it is computed on every status update
+	ConditionReady HelmAppConditionType = "Ready"
+	// ConditionVerified reports the signature verification of the chart of a source that verifies it
+	ConditionVerified HelmAppConditionType = "Verified"
+	// ConditionPolicyViolation lists the policy rules that the rendered manifests of the chart violate
+	ConditionPolicyViolation HelmAppConditionType = "PolicyViolation"
+	// ConditionValuesInvalid lists the values that do not match the values.schema.json of the chart
+	ConditionValuesInvalid HelmAppConditionType = "ValuesInvalid"
 
 	StatusTrue    ConditionStatus = "True"
 	StatusFalse   ConditionStatus = "False"
@@ -232,11 +506,40 @@ const (
 	ReasonUpgradeError        HelmAppConditionReason = "UpgradeError"
 	ReasonReconcileError      HelmAppConditionReason = "ReconcileError"
 	ReasonUninstallError      HelmAppConditionReason = "UninstallError"
+	ReasonAdoptionError       HelmAppConditionReason = "AdoptionError"
+	ReasonOrphanSuccessful    HelmAppConditionReason = "OrphanSuccessful"
+	ReasonUninstallForced     HelmAppConditionReason = "UninstallForced"
+	ReasonReconciling         HelmAppConditionReason = "Reconciling"
+	ReasonDeleting            HelmAppConditionReason = "Deleting"
+	ReasonReady               HelmAppConditionReason = "Ready"
+	ReasonChartVerified       HelmAppConditionReason = "ChartVerified"
+	ReasonVerificationFailed  HelmAppConditionReason = "VerificationFailed"
+	ReasonPolicyViolation     HelmAppConditionReason = "PolicyViolation"
+	ReasonDryRunFailed        HelmAppConditionReason = "DryRunFailed"
+	ReasonValuesInvalid       HelmAppConditionReason = "ValuesInvalid"
 )
 
+// HelmAppChartSource identifies where the current chart was fetched from
+type HelmAppChartSource struct {
+	// Name is the name of the candidate source that served the chart
+	Name string         `json:"name,omitempty"`
+	Type SourceTypeEnum `json:"type,omitempty"`
+	// Description is the description of the source given by its chart source
+	Description string `json:"description,omitempty"`
+	// Location is the url the chart was fetched from
+	Location string `json:"location,omitempty"`
+	// Revision identifies the fetched content, for example the git commit or the digest of the chart archive
+	Revision string `json:"revision,omitempty"`
+	// SignedBy identifies the key that signed the chart when the source verifies the signature of the chart
+	SignedBy string `json:"signedBy,omitempty"`
+}
+
 type HelmAppStatus struct {
-	Conditions      []HelmAppCondition `json:"conditions"`
-	DeployedRelease *HelmAppRelease    `json:"deployedRelease,omitempty"`
+	// ObservedGeneration is the generation of the HelmRelease the status was computed for
+	ObservedGeneration int64               `json:"observedGeneration,omitempty"`
+	Conditions         []HelmAppCondition  `json:"conditions"`
+	DeployedRelease    *HelmAppRelease     `json:"deployedRelease,omitempty"`
+	ChartSource        *HelmAppChartSource `json:"chartSource,omitempty"`
 }
 
 func (s *HelmAppStatus) ToMap() (map[string]interface{}, error) {
@@ -279,6 +582,57 @@ func (s *HelmAppStatus) SetCondition(condition HelmAppCondition) *HelmAppStatus
 	return s
 }
 
+// GetCondition returns the condition with the passed condition type, or nil if it is not present.
+func (s *HelmAppStatus) GetCondition(conditionType HelmAppConditionType) *HelmAppCondition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+
+	return nil
+}
+
+// IsConditionTrue returns true if the condition with the passed condition type is present and true.
+func (s *HelmAppStatus) IsConditionTrue(conditionType HelmAppConditionType) bool {
+	c := s.GetCondition(conditionType)
+
+	return c != nil && c.Status == StatusTrue
+}
+
+// SetReadyCondition computes the Ready condition from the Initialized, Deployed, ReleaseFailed and
+// Irreconcilable conditions and records the generation it was computed for. The release is ready
+// once it is initialized and deployed without any failure. SetReadyCondition does not update the
+// resource in the cluster.
+func (s *HelmAppStatus) SetReadyCondition(generation int64, deleting bool) *HelmAppStatus {
+	ready := HelmAppCondition{
+		Type:               ConditionReady,
+		Status:             StatusFalse,
+		ObservedGeneration: generation,
+	}
+
+	switch {
+	case deleting:
+		ready.Reason = ReasonDeleting
+	case s.IsConditionTrue(ConditionIrreconcilable):
+		failed := s.GetCondition(ConditionIrreconcilable)
+		ready.Reason, ready.Message = failed.Reason, failed.Message
+	case s.IsConditionTrue(ConditionReleaseFailed):
+		failed := s.GetCondition(ConditionReleaseFailed)
+		ready.Reason, ready.Message = failed.Reason, failed.Message
+	case s.IsConditionTrue(ConditionInitialized) && s.IsConditionTrue(ConditionDeployed):
+		ready.Status = StatusTrue
+		ready.Reason = ReasonReady
+	default:
+		ready.Status = StatusUnknown
+		ready.Reason = ReasonReconciling
+	}
+
+	s.ObservedGeneration = generation
+
+	return s.SetCondition(ready)
+}
+
 // RemoveCondition removes the condition with the passed condition type from
 // the status object. If the condition is not already present, the returned
 // status object is returned unchanged. RemoveCondition does not update the
@@ -0,0 +1,86 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetReadyCondition(t *testing.T) {
+	tests := []struct {
+		name           string
+		conditions     []HelmAppCondition
+		deleting       bool
+		expectedStatus ConditionStatus
+		expectedReason HelmAppConditionReason
+	}{
+		{
+			name:           "no conditions yet",
+			expectedStatus: StatusUnknown,
+			expectedReason: ReasonReconciling,
+		},
+		{
+			name: "initialized and deployed",
+			conditions: []HelmAppCondition{
+				{Type: ConditionInitialized, Status: StatusTrue},
+				{Type: ConditionDeployed, Status: StatusTrue, Reason: ReasonInstallSuccessful},
+			},
+			expectedStatus: StatusTrue,
+			expectedReason: ReasonReady,
+		},
+		{
+			name: "deployed but the upgrade failed",
+			conditions: []HelmAppCondition{
+				{Type: ConditionInitialized, Status: StatusTrue},
+				{Type: ConditionDeployed, Status: StatusTrue, Reason: ReasonInstallSuccessful},
+				{Type: ConditionReleaseFailed, Status: StatusTrue, Reason: ReasonUpgradeError},
+			},
+			expectedStatus: StatusFalse,
+			expectedReason: ReasonUpgradeError,
+		},
+		{
+			name: "irreconcilable",
+			conditions: []HelmAppCondition{
+				{Type: ConditionIrreconcilable, Status: StatusTrue, Reason: ReasonReconcileError},
+			},
+			expectedStatus: StatusFalse,
+			expectedReason: ReasonReconcileError,
+		},
+		{
+			name: "deleting",
+			conditions: []HelmAppCondition{
+				{Type: ConditionInitialized, Status: StatusTrue},
+				{Type: ConditionDeployed, Status: StatusTrue, Reason: ReasonInstallSuccessful},
+			},
+			deleting:       true,
+			expectedStatus: StatusFalse,
+			expectedReason: ReasonDeleting,
+		},
+	}
+
+	for _, test := range tests {
+		status := &HelmAppStatus{Conditions: test.conditions}
+		status.SetReadyCondition(3, test.deleting)
+
+		ready := status.GetCondition(ConditionReady)
+		assert.NotNil(t, ready, test.name)
+		assert.Equal(t, test.expectedStatus, ready.Status, test.name)
+		assert.Equal(t, test.expectedReason, ready.Reason, test.name)
+		assert.Equal(t, int64(3), ready.ObservedGeneration, test.name)
+		assert.Equal(t, int64(3), status.ObservedGeneration, test.name)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/ghodss/yaml"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -43,6 +44,21 @@ func (in *AltSource) DeepCopyInto(out *AltSource) {
 		*out = new(HelmRepo)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(S3)
+		**out = **in
+	}
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(ConfigMapSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OCI != nil {
+		in, out := &in.OCI, &out.OCI
+		*out = new(OCI)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.SecretRef != nil {
 		in, out := &in.SecretRef, &out.SecretRef
 		*out = new(corev1.ObjectReference)
@@ -65,6 +81,51 @@ func (in *AltSource) DeepCopy() *AltSource {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapSource) DeepCopyInto(out *ConfigMapSource) {
+	*out = *in
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapSource.
+func (in *ConfigMapSource) DeepCopy() *ConfigMapSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CosignVerification) DeepCopyInto(out *CosignVerification) {
+	*out = *in
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(corev1.ObjectReference)
+		**out = **in
+	}
+	if in.ConfigMapRef != nil {
+		in, out := &in.ConfigMapRef, &out.ConfigMapRef
+		*out = new(corev1.ObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CosignVerification.
+func (in *CosignVerification) DeepCopy() *CosignVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(CosignVerification)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Git) DeepCopyInto(out *Git) {
 	*out = *in
@@ -105,6 +166,21 @@ func (in *GitHub) DeepCopy() *GitHub {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmAppChartSource) DeepCopyInto(out *HelmAppChartSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmAppChartSource.
+func (in *HelmAppChartSource) DeepCopy() *HelmAppChartSource {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmAppChartSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HelmAppCondition) DeepCopyInto(out *HelmAppCondition) {
 	*out = *in
@@ -151,6 +227,11 @@ func (in *HelmAppStatus) DeepCopyInto(out *HelmAppStatus) {
 		*out = new(HelmAppRelease)
 		**out = **in
 	}
+	if in.ChartSource != nil {
+		in, out := &in.ChartSource, &out.ChartSource
+		*out = new(HelmAppChartSource)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmAppStatus.
@@ -169,6 +250,26 @@ func (in *HelmRelease) DeepCopyInto(out *HelmRelease) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Repo.DeepCopyInto(&out.Repo)
+	if in.Install != nil {
+		in, out := &in.Install, &out.Install
+		*out = new(HelmReleaseInstall)
+		**out = **in
+	}
+	if in.Upgrade != nil {
+		in, out := &in.Upgrade, &out.Upgrade
+		*out = new(HelmReleaseUpgrade)
+		**out = **in
+	}
+	if in.Validation != nil {
+		in, out := &in.Validation, &out.Validation
+		*out = new(HelmReleaseValidation)
+		**out = **in
+	}
+	if in.Uninstall != nil {
+		in, out := &in.Uninstall, &out.Uninstall
+		*out = new(HelmReleaseUninstall)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Spec != nil {
 		// Modified after auto gen
 		byt, err := yaml.Marshal(in.Spec)
@@ -198,6 +299,21 @@ func (in *HelmRelease) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmReleaseInstall) DeepCopyInto(out *HelmReleaseInstall) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmReleaseInstall.
+func (in *HelmReleaseInstall) DeepCopy() *HelmReleaseInstall {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmReleaseInstall)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HelmReleaseList) DeepCopyInto(out *HelmReleaseList) {
 	*out = *in
@@ -253,6 +369,13 @@ func (in *HelmReleaseRepo) DeepCopyInto(out *HelmReleaseRepo) {
 		*out = new(corev1.ObjectReference)
 		**out = **in
 	}
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]AltSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmReleaseRepo.
@@ -265,6 +388,61 @@ func (in *HelmReleaseRepo) DeepCopy() *HelmReleaseRepo {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmReleaseUninstall) DeepCopyInto(out *HelmReleaseUninstall) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ForceAfter != nil {
+		in, out := &in.ForceAfter, &out.ForceAfter
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmReleaseUninstall.
+func (in *HelmReleaseUninstall) DeepCopy() *HelmReleaseUninstall {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmReleaseUninstall)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmReleaseUpgrade) DeepCopyInto(out *HelmReleaseUpgrade) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmReleaseUpgrade.
+func (in *HelmReleaseUpgrade) DeepCopy() *HelmReleaseUpgrade {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmReleaseUpgrade)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HelmReleaseValidation) DeepCopyInto(out *HelmReleaseValidation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmReleaseValidation.
+func (in *HelmReleaseValidation) DeepCopy() *HelmReleaseValidation {
+	if in == nil {
+		return nil
+	}
+	out := new(HelmReleaseValidation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HelmRepo) DeepCopyInto(out *HelmRepo) {
 	*out = *in
@@ -273,6 +451,16 @@ func (in *HelmRepo) DeepCopyInto(out *HelmRepo) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.KeyringSecretRef != nil {
+		in, out := &in.KeyringSecretRef, &out.KeyringSecretRef
+		*out = new(corev1.ObjectReference)
+		**out = **in
+	}
+	if in.Cosign != nil {
+		in, out := &in.Cosign, &out.Cosign
+		*out = new(CosignVerification)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HelmRepo.
@@ -285,6 +473,41 @@ func (in *HelmRepo) DeepCopy() *HelmRepo {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCI) DeepCopyInto(out *OCI) {
+	*out = *in
+	if in.Cosign != nil {
+		in, out := &in.Cosign, &out.Cosign
+		*out = new(CosignVerification)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCI.
+func (in *OCI) DeepCopy() *OCI {
+	if in == nil {
+		return nil
+	}
+	out := new(OCI)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S3) DeepCopyInto(out *S3) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3.
+func (in *S3) DeepCopy() *S3 {
+	if in == nil {
+		return nil
+	}
+	out := new(S3)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Source) DeepCopyInto(out *Source) {
 	*out = *in
@@ -303,6 +526,21 @@ func (in *Source) DeepCopyInto(out *Source) {
 		*out = new(HelmRepo)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(S3)
+		**out = **in
+	}
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(ConfigMapSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OCI != nil {
+		in, out := &in.OCI, &out.OCI
+		*out = new(OCI)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Source.
@@ -0,0 +1,175 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"encoding/json"
+	"fmt"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// ConvertTo converts this HelmRelease to the v1 hub version
+func (src *HelmRelease) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*appv1.HelmRelease)
+	if !ok {
+		return fmt.Errorf("unsupported conversion hub %T", dstRaw)
+	}
+
+	dst.ObjectMeta = src.ObjectMeta
+
+	dst.Repo = appv1.HelmReleaseRepo{
+		ChartName:          src.Spec.Chart.Name,
+		Version:            src.Spec.Chart.Version,
+		Digest:             src.Spec.Chart.Digest,
+		Source:             src.Spec.Source.toV1Source(),
+		AltSource:          src.Spec.Source.AltSource,
+		SecretRef:          src.Spec.Source.SecretRef,
+		ConfigMapRef:       src.Spec.Source.ConfigMapRef,
+		InsecureSkipVerify: src.Spec.Source.InsecureSkipVerify,
+		Sources:            src.Spec.Source.Sources,
+		FailoverPolicy:     src.Spec.Source.FailoverPolicy,
+	}
+
+	dst.Install = src.Spec.Install
+	dst.Upgrade = src.Spec.Upgrade
+	dst.Validation = src.Spec.Validation
+	dst.Uninstall = src.Spec.Uninstall
+
+	spec, err := valuesToSpec(src.Spec.Values)
+	if err != nil {
+		return fmt.Errorf("failed to convert the values of HelmRelease %s/%s: %w", src.Namespace, src.Name, err)
+	}
+
+	dst.Spec = spec
+	dst.Status = src.Status
+
+	return nil
+}
+
+// ConvertFrom converts the v1 hub version to this HelmRelease
+func (dst *HelmRelease) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*appv1.HelmRelease)
+	if !ok {
+		return fmt.Errorf("unsupported conversion hub %T", srcRaw)
+	}
+
+	dst.ObjectMeta = src.ObjectMeta
+
+	dst.Spec.Chart = HelmReleaseChart{
+		Name:    src.Repo.ChartName,
+		Version: src.Repo.Version,
+		Digest:  src.Repo.Digest,
+	}
+
+	dst.Spec.Source = HelmReleaseSource{
+		AltSource:          src.Repo.AltSource,
+		SecretRef:          src.Repo.SecretRef,
+		ConfigMapRef:       src.Repo.ConfigMapRef,
+		InsecureSkipVerify: src.Repo.InsecureSkipVerify,
+		Sources:            src.Repo.Sources,
+		FailoverPolicy:     src.Repo.FailoverPolicy,
+	}
+
+	if src.Repo.Source != nil {
+		dst.Spec.Source.SourceType = src.Repo.Source.SourceType
+		dst.Spec.Source.GitHub = src.Repo.Source.GitHub
+		dst.Spec.Source.Git = src.Repo.Source.Git
+		dst.Spec.Source.HelmRepo = src.Repo.Source.HelmRepo
+		dst.Spec.Source.S3 = src.Repo.Source.S3
+		dst.Spec.Source.ConfigMap = src.Repo.Source.ConfigMap
+		dst.Spec.Source.OCI = src.Repo.Source.OCI
+	}
+
+	dst.Spec.Install = src.Install
+	dst.Spec.Upgrade = src.Upgrade
+	dst.Spec.Validation = src.Validation
+	dst.Spec.Uninstall = src.Uninstall
+
+	values, err := specToValues(src.Spec)
+	if err != nil {
+		return fmt.Errorf("failed to convert the spec of HelmRelease %s/%s: %w", src.Namespace, src.Name, err)
+	}
+
+	dst.Spec.Values = values
+	dst.Status = src.Status
+
+	return nil
+}
+
+func (s HelmReleaseSource) toV1Source() *appv1.Source {
+	if s.SourceType == "" && s.GitHub == nil && s.Git == nil && s.HelmRepo == nil && s.S3 == nil &&
+		s.ConfigMap == nil && s.OCI == nil {
+		return nil
+	}
+
+	return &appv1.Source{
+		SourceType: s.SourceType,
+		GitHub:     s.GitHub,
+		Git:        s.Git,
+		HelmRepo:   s.HelmRepo,
+		S3:         s.S3,
+		ConfigMap:  s.ConfigMap,
+		OCI:        s.OCI,
+	}
+}
+
+// valuesToSpec returns the v1 spec for the v2 values. A HelmRelease without values has no v1 spec.
+func valuesToSpec(values *apiextensionsv1.JSON) (appv1.HelmAppSpec, error) {
+	if values == nil || len(values.Raw) == 0 {
+		return nil, nil
+	}
+
+	spec := map[string]interface{}{}
+	if err := json.Unmarshal(values.Raw, &spec); err != nil {
+		return nil, err
+	}
+
+	if len(spec) == 0 {
+		return nil, nil
+	}
+
+	return spec, nil
+}
+
+// specToValues returns the v2 values for the v1 spec. The {"":""} spec that older versions of the
+// operator set on a HelmRelease without spec is dropped, it stands for the default chart values.
+func specToValues(spec appv1.HelmAppSpec) (*apiextensionsv1.JSON, error) {
+	if spec == nil {
+		return nil, nil
+	}
+
+	if m, ok := spec.(map[string]interface{}); ok {
+		if v, found := m[""]; found && v == "" && len(m) == 1 {
+			return nil, nil
+		}
+
+		if len(m) == 0 {
+			return nil, nil
+		}
+	}
+
+	raw, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiextensionsv1.JSON{Raw: raw}, nil
+}
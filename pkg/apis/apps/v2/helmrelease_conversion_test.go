@@ -0,0 +1,101 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestConvertRoundTrip(t *testing.T) {
+	v2hr := &HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "default"},
+		Spec: HelmReleaseSpec{
+			Chart: HelmReleaseChart{Name: "nginx-ingress", Version: "1.26.0"},
+			Source: HelmReleaseSource{
+				SourceType: appv1.HelmRepoSourceType,
+				HelmRepo:   &appv1.HelmRepo{Urls: []string{"https://charts.example.com/nginx-ingress-1.26.0.tgz"}},
+				AltSource: &appv1.AltSource{
+					SourceType: appv1.GitSourceType,
+					Git:        &appv1.Git{Urls: []string{"https://github.com/helm/charts"}, ChartPath: "stable/nginx-ingress"},
+				},
+				InsecureSkipVerify: true,
+				Sources: []appv1.AltSource{{
+					Name:       "mirror",
+					SourceType: appv1.HelmRepoSourceType,
+					HelmRepo:   &appv1.HelmRepo{Urls: []string{"https://mirror.example.com/nginx-ingress-1.26.0.tgz"}},
+				}},
+				FailoverPolicy: appv1.FailoverPolicyPreferred,
+			},
+			Values:     &apiextensionsv1.JSON{Raw: []byte(`{"defaultBackend":{"replicaCount":1}}`)},
+			Upgrade:    &appv1.HelmReleaseUpgrade{ServerDryRun: true},
+			Validation: &appv1.HelmReleaseValidation{RejectUnknownValues: true},
+			Uninstall:  &appv1.HelmReleaseUninstall{DeletionPolicy: appv1.DeletionPolicyOrphan},
+		},
+	}
+
+	v1hr := &appv1.HelmRelease{}
+	assert.NoError(t, v2hr.ConvertTo(v1hr))
+
+	assert.Equal(t, "nginx-ingress", v1hr.Repo.ChartName)
+	assert.Equal(t, "1.26.0", v1hr.Repo.Version)
+	assert.Equal(t, appv1.HelmRepoSourceType, v1hr.Repo.Source.SourceType)
+	assert.Equal(t, v2hr.Spec.Source.AltSource, v1hr.Repo.AltSource)
+	assert.True(t, v1hr.Repo.InsecureSkipVerify)
+	assert.True(t, v1hr.Upgrade.ServerDryRuns())
+	assert.True(t, v1hr.Validation.RejectsUnknownValues())
+	assert.Equal(t, appv1.DeletionPolicyOrphan, v1hr.Uninstall.GetDeletionPolicy())
+	assert.Equal(t, map[string]interface{}{
+		"defaultBackend": map[string]interface{}{"replicaCount": float64(1)},
+	}, v1hr.Spec)
+
+	back := &HelmRelease{}
+	assert.NoError(t, back.ConvertFrom(v1hr))
+	assert.Equal(t, v2hr.Spec.Chart, back.Spec.Chart)
+	assert.Equal(t, v2hr.Spec.Source, back.Spec.Source)
+	assert.Equal(t, v2hr.Spec.Upgrade, back.Spec.Upgrade)
+	assert.Equal(t, v2hr.Spec.Validation, back.Spec.Validation)
+	assert.Equal(t, v2hr.Spec.Uninstall, back.Spec.Uninstall)
+	assert.JSONEq(t, string(v2hr.Spec.Values.Raw), string(back.Spec.Values.Raw))
+}
+
+func TestConvertDefaultValues(t *testing.T) {
+	v1hr := &appv1.HelmRelease{
+		Repo: appv1.HelmReleaseRepo{ChartName: "nginx-ingress"},
+		Spec: map[string]interface{}{"": ""},
+	}
+
+	v2hr := &HelmRelease{}
+	assert.NoError(t, v2hr.ConvertFrom(v1hr))
+	assert.Nil(t, v2hr.Spec.Values)
+
+	back := &appv1.HelmRelease{}
+	assert.NoError(t, v2hr.ConvertTo(back))
+	assert.Nil(t, back.Spec)
+	assert.Nil(t, back.Repo.Source)
+}
+
+func TestConvertToInvalidValues(t *testing.T) {
+	v2hr := &HelmRelease{
+		Spec: HelmReleaseSpec{Values: &apiextensionsv1.JSON{Raw: []byte(`["not","an","object"]`)}},
+	}
+
+	assert.Error(t, v2hr.ConvertTo(&appv1.HelmRelease{}))
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// HelmReleaseChart identifies the chart to install
+// +k8s:openapi-gen=true
+type HelmReleaseChart struct {
+	// Name is the name of the chart within the repo
+	Name string `json:"name,omitempty"`
+	// Version is the chart version
+	Version string `json:"version,omitempty"`
+	// Digest is the helm repo chart digest
+	Digest string `json:"digest,omitempty"`
+}
+
+// HelmReleaseSource defines where the chart is downloaded from
+// +k8s:openapi-gen=true
+type HelmReleaseSource struct {
+	// Type is the type of the source, one of helmrepo, github, git, s3, configmap or oci
+	SourceType appv1.SourceTypeEnum   `json:"type,omitempty"`
+	GitHub     *appv1.GitHub          `json:"github,omitempty"`
+	Git        *appv1.Git             `json:"git,omitempty"`
+	HelmRepo   *appv1.HelmRepo        `json:"helmRepo,omitempty"`
+	S3         *appv1.S3              `json:"s3,omitempty"`
+	ConfigMap  *appv1.ConfigMapSource `json:"configMap,omitempty"`
+	OCI        *appv1.OCI             `json:"oci,omitempty"`
+	// AltSource is tried when the chart cannot be downloaded from the source
+	AltSource *appv1.AltSource `json:"altSource,omitempty"`
+	// Secret to use to access the source
+	SecretRef *corev1.ObjectReference `json:"secretRef,omitempty"`
+	// Configuration parameters to access the source
+	ConfigMapRef *corev1.ObjectReference `json:"configMapRef,omitempty"`
+	// InsecureSkipVerify is used to skip repo server's TLS certificate verification
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+	// Sources are more sources tried after the source and AltSource, each with its own credentials and TLS settings
+	Sources []appv1.AltSource `json:"sources,omitempty"`
+	// FailoverPolicy is FirstSuccess (default) or Preferred
+	// +kubebuilder:validation:Enum=FirstSuccess;Preferred
+	FailoverPolicy appv1.FailoverPolicy `json:"failoverPolicy,omitempty"`
+}
+
+// HelmReleaseSpec defines the desired state of HelmRelease
+// +k8s:openapi-gen=true
+type HelmReleaseSpec struct {
+	// Chart identifies the chart to install
+	Chart HelmReleaseChart `json:"chart,omitempty"`
+	// Source holds the url toward the helm-chart
+	Source HelmReleaseSource `json:"source,omitempty"`
+	// Values are the values used to render the chart
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Values *apiextensionsv1.JSON `json:"values,omitempty"`
+	// Install defines how the HelmRelease takes over releases and objects it did not create
+	Install *appv1.HelmReleaseInstall `json:"install,omitempty"`
+	// Upgrade defines how the release is upgraded
+	Upgrade *appv1.HelmReleaseUpgrade `json:"upgrade,omitempty"`
+	// Validation defines how the values are validated against the chart
+	Validation *appv1.HelmReleaseValidation `json:"validation,omitempty"`
+	// Uninstall defines how the release is removed when the HelmRelease is deleted
+	Uninstall *appv1.HelmReleaseUninstall `json:"uninstall,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// HelmRelease is the Schema for the subscriptionreleases API
+// +k8s:openapi-gen=true
+// +kubebuilder:resource:scope=Namespaced
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Chart",type=string,JSONPath=`.status.deployedRelease.chartName`
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.deployedRelease.chartVersion`
+// +kubebuilder:printcolumn:name="Revision",type=integer,JSONPath=`.status.deployedRelease.revision`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type HelmRelease struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HelmReleaseSpec     `json:"spec,omitempty"`
+	Status appv1.HelmAppStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// HelmReleaseList contains a list of HelmRelease
+type HelmReleaseList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HelmRelease `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HelmRelease{}, &HelmReleaseList{})
+}
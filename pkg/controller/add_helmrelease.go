@@ -17,10 +17,17 @@ limitations under the License.
 package controller
 
 import (
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
 	"github.com/stolostron/multicloud-operators-subscription-release/pkg/controller/helmrelease"
 )
 
+// HelmReleaseOptions configures the helmrelease controller, it must be set before AddToManager
+var HelmReleaseOptions = helmrelease.DefaultOptions()
+
 func init() {
 	// AddToManagerFuncs is a list of functions to create controllers and add them to a manager.
-	AddToManagerFuncs = append(AddToManagerFuncs, helmrelease.Add)
+	AddToManagerFuncs = append(AddToManagerFuncs, func(m manager.Manager) error {
+		return helmrelease.AddWithOptions(m, HelmReleaseOptions)
+	})
 }
@@ -25,15 +25,20 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/ghodss/yaml"
 	"helm.sh/helm/v3/pkg/kube"
+	rpb "helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/releaseutil"
 	"helm.sh/helm/v3/pkg/storage/driver"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -47,9 +52,12 @@ import (
 	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
 	"github.com/stolostron/multicloud-operators-subscription-release/pkg/release"
 	helmoperator "github.com/stolostron/multicloud-operators-subscription-release/pkg/release"
+	"github.com/stolostron/multicloud-operators-subscription-release/pkg/utils"
 )
 
 const (
+	controllerName = "helmrelease-controller"
+
 	finalizer = "uninstall-helm-release"
 
 	defaultMaxConcurrent = 10
@@ -58,16 +66,23 @@ const (
 // Add creates a new HelmRelease Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func Add(mgr manager.Manager) error {
-	return add(mgr, newReconciler(mgr))
+	return AddWithOptions(mgr, DefaultOptions())
+}
+
+// AddWithOptions creates a new HelmRelease Controller configured by the options and adds it to the Manager
+func AddWithOptions(mgr manager.Manager, options Options) error {
+	options = options.withDefaults()
+
+	return add(mgr, newReconciler(mgr, options), options)
 }
 
 // newReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileHelmRelease{mgr}
+func newReconciler(mgr manager.Manager, options Options) reconcile.Reconciler {
+	return &ReconcileHelmRelease{Manager: mgr, options: options}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
-func add(mgr manager.Manager, r reconcile.Reconciler) error {
+func add(mgr manager.Manager, r reconcile.Reconciler, options Options) error {
 	chartsDir := os.Getenv(appv1.ChartsDir)
 	if chartsDir == "" {
 		chartsDir = "/tmp/hr-charts"
@@ -78,10 +93,14 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		}
 	}
 
-	klog.Info("The MaxConcurrentReconciles is set to: ", defaultMaxConcurrent)
+	klog.Info("The MaxConcurrentReconciles is set to: ", options.MaxConcurrentReconciles)
 
 	// Create a new controller
-	c, err := controller.New("helmrelease-controller", mgr, controller.Options{Reconciler: r, MaxConcurrentReconciles: defaultMaxConcurrent})
+	c, err := controller.New(controllerName, mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: options.MaxConcurrentReconciles,
+		RateLimiter:             options.rateLimiter(),
+	})
 	if err != nil {
 		return err
 	}
@@ -92,6 +111,20 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Watch for changes to the ConfigMaps and Secrets holding the charts of the configmap sources
+	if err := mgr.GetFieldIndexer().IndexField(context.TODO(), &appv1.HelmRelease{}, chartObjectsField,
+		indexChartObjects); err != nil {
+		return err
+	}
+
+	for _, kind := range []string{utils.ConfigMapKind, utils.SecretKind} {
+		if err := c.Watch(&source.Kind{Type: chartObjectMetadata(kind)},
+			handler.EnqueueRequestsFromMapFunc(chartObjectMapper(mgr.GetClient(), kind)),
+			chartObjectPredicate(mgr.GetClient(), kind)); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -101,6 +134,7 @@ var _ reconcile.Reconciler = &ReconcileHelmRelease{}
 // ReconcileHelmRelease reconciles a HelmRelease object
 type ReconcileHelmRelease struct {
 	manager.Manager
+	options Options
 }
 
 // Reconcile reads that state of the cluster for a HelmRelease object and makes changes based on the state read
@@ -119,6 +153,8 @@ func (r *ReconcileHelmRelease) Reconcile(ctx context.Context, request reconcile.
 		klog.Info("Ignorable error. Failed to find HelmRelease, most likely it has been uninstalled: ",
 			helmreleaseNsn(instance), " ", err)
 
+		healthOfSources.forget(instance)
+
 		return reconcile.Result{}, nil
 	}
 	if err != nil {
@@ -126,69 +162,28 @@ func (r *ReconcileHelmRelease) Reconcile(ctx context.Context, request reconcile.
 		return reconcile.Result{}, err
 	}
 
-	if instance.Repo.Source == nil {
+	if len(instance.Repo.CandidateSources()) == 0 {
 		klog.Error("Failed to detect Repo.Source from HelmRelease ", helmreleaseNsn(instance), ". Setting requeue to false.")
 		//TODO set error status here
 
 		return reconcile.Result{Requeue: false}, nil
 	}
 
-	// setting the nil spec to "":"" allows helmrelease to reconcile with default chart values.
-	if instance.Spec == nil && instance.GetDeletionTimestamp() == nil {
-		spec := make(map[string]interface{})
-
-		err := yaml.Unmarshal([]byte("{\"\":\"\"}"), &spec)
-		if err != nil {
-			klog.Error("Failed to unmarshal default spec: ",
-				helmreleaseNsn(instance), " ", err)
-
-			return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
-		}
-
-		instance.Spec = spec
-
-		err = r.GetClient().Update(context.TODO(), instance)
-		if err != nil {
-			klog.Error("Failed to update HelmRelease with default spec: ",
-				helmreleaseNsn(instance), " ", err)
-
-			return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
-		}
-	}
-
 	// handles the download of the chart as well
-	helmOperatorManagerFactory, err := r.newHelmOperatorManagerFactory(instance)
+	helmOperatorManagerFactory, err := r.newHelmOperatorManagerFactoryFromSources(instance)
 	if err != nil {
-		altSourcePassed := false
-
-		if instance.Repo.AltSource != nil {
-			klog.Warning("Attempting AltSource to create new HelmOperatorManagerFactory because Source failed: ",
-				helmreleaseNsn(instance), " ", err)
-
-			repoClone := instance.Repo.Clone()
-			instance.Repo = instance.Repo.AltSourceToSource()
-
-			helmOperatorManagerFactory, err = r.newHelmOperatorManagerFactory(instance)
-			if err == nil {
-				altSourcePassed = true
-				instance.Repo = repoClone
-			}
-		}
-
-		if !altSourcePassed {
-			klog.Error("Failed to create new HelmOperatorManagerFactory: ",
-				helmreleaseNsn(instance), " ", err)
+		klog.Error("Failed to create new HelmOperatorManagerFactory: ",
+			helmreleaseNsn(instance), " ", err)
 
-			instance.Status.SetCondition(appv1.HelmAppCondition{
-				Type:    appv1.ConditionIrreconcilable,
-				Status:  appv1.StatusTrue,
-				Reason:  appv1.ReasonReconcileError,
-				Message: err.Error(),
-			})
-			_ = r.updateResourceStatus(instance)
+		instance.Status.SetCondition(appv1.HelmAppCondition{
+			Type:    appv1.ConditionIrreconcilable,
+			Status:  appv1.StatusTrue,
+			Reason:  appv1.ReasonReconcileError,
+			Message: err.Error(),
+		})
+		_ = r.updateResourceStatus(instance)
 
-			return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
-		}
+		return r.downloadRetry(), nil
 	}
 
 	manager, err := r.newHelmOperatorManager(instance, request, helmOperatorManagerFactory)
@@ -204,7 +199,7 @@ func (r *ReconcileHelmRelease) Reconcile(ctx context.Context, request reconcile.
 		})
 		_ = r.updateResourceStatus(instance)
 
-		return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+		return r.releaseRetry(), nil
 	}
 
 	// hack for MultiClusterHub to remove CRD outside of Helm/HelmRelease's control
@@ -226,6 +221,10 @@ func (r *ReconcileHelmRelease) Reconcile(ctx context.Context, request reconcile.
 		Status: appv1.StatusTrue,
 	})
 
+	if err := r.validateValues(instance, manager); err != nil {
+		return r.releaseRetry(), nil
+	}
+
 	klog.Info("Sync Release ", helmreleaseNsn(instance))
 
 	if err := manager.Sync(context.TODO()); err != nil {
@@ -241,7 +240,7 @@ func (r *ReconcileHelmRelease) Reconcile(ctx context.Context, request reconcile.
 
 		klog.Info("Requeue HelmRelease after one minute ")
 
-		return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+		return r.releaseRetry(), nil
 	}
 
 	instance.Status.RemoveCondition(appv1.ConditionIrreconcilable)
@@ -250,12 +249,31 @@ func (r *ReconcileHelmRelease) Reconcile(ctx context.Context, request reconcile.
 		return r.install(instance, manager)
 	}
 
+	// a deployed release that this HelmRelease has never reported is a release created outside of the operator
+	if instance.Install.AdoptsRelease() && instance.Status.DeployedRelease == nil {
+		if err := manager.AdoptRelease(context.TODO()); err != nil {
+			klog.Error("Failed to adopt release for HelmRelease ", helmreleaseNsn(instance), " ", err)
+
+			instance.Status.SetCondition(appv1.HelmAppCondition{
+				Type:    appv1.ConditionIrreconcilable,
+				Status:  appv1.StatusTrue,
+				Reason:  appv1.ReasonAdoptionError,
+				Message: err.Error(),
+			})
+			_ = r.updateResourceStatus(instance)
+
+			return r.releaseRetry(), nil
+		}
+
+		klog.Info("Adopted release for HelmRelease ", helmreleaseNsn(instance))
+	}
+
 	if !contains(instance.GetFinalizers(), finalizer) {
 		klog.V(1).Info("Adding finalizer (", finalizer, ") to ", helmreleaseNsn(instance))
 		controllerutil.AddFinalizer(instance, finalizer)
 		if err := r.updateResource(instance); err != nil {
 			klog.Error("Failed to add uninstall finalizer to ", helmreleaseNsn(instance))
-			return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+			return r.releaseRetry(), nil
 		}
 	}
 
@@ -270,11 +288,14 @@ func (r *ReconcileHelmRelease) Reconcile(ctx context.Context, request reconcile.
 	// need to remove the ConditionReleaseFailed because the failing release is
 	// no longer being attempted.
 	instance.Status.RemoveCondition(appv1.ConditionReleaseFailed)
+	instance.Status.RemoveCondition(appv1.ConditionPolicyViolation)
 
 	return r.ensureStatusReasonPopulated(instance, manager)
 }
 
 func (r ReconcileHelmRelease) updateResourceStatus(hr *appv1.HelmRelease) error {
+	hr.Status.SetReadyCondition(hr.GetGeneration(), hr.GetDeletionTimestamp() != nil)
+
 	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
 		return r.GetClient().Status().Update(context.TODO(), hr)
 	})
@@ -324,9 +345,19 @@ func (r *ReconcileHelmRelease) install(instance *appv1.HelmRelease, manager helm
 		rollbackByUninstall = false
 	}
 
+	if err := r.checkPolicies(instance, manager); err != nil {
+		return r.releaseRetry(), nil
+	}
+
+	if err := r.takeOwnership(instance, manager); err != nil {
+		return r.releaseRetry(), nil
+	}
+
 	klog.Info("Installing Release ", helmreleaseNsn(instance))
 
-	installedRelease, err := manager.InstallRelease(context.TODO())
+	replace := instance.Install != nil && instance.Install.Replace
+
+	installedRelease, err := manager.InstallRelease(context.TODO(), release.ReplaceRelease(replace))
 	if err != nil {
 		klog.Error("Failed to install HelmRelease ",
 			helmreleaseNsn(instance), " ", err)
@@ -344,7 +375,7 @@ func (r *ReconcileHelmRelease) install(instance *appv1.HelmRelease, manager helm
 			if errRemoveCRDs := r.hackMultiClusterHubRemoveCRDReferences(instance, manager.GetActionConfig()); errRemoveCRDs != nil {
 				klog.Error("Failed to hackMultiClusterHubRemoveCRDReferences: ", errRemoveCRDs)
 
-				return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+				return r.releaseRetry(), nil
 			}
 
 			klog.Info("Failed to install HelmRelease and the installedRelease response is not nil. Proceed to uninstall ",
@@ -363,13 +394,13 @@ func (r *ReconcileHelmRelease) install(instance *appv1.HelmRelease, manager helm
 				})
 				_ = r.updateResourceStatus(instance)
 
-				return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+				return r.releaseRetry(), nil
 			}
 
 			klog.Info("Uninstalled Release for install failure ", helmreleaseNsn(instance))
 		}
 
-		return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+		return r.releaseRetry(), nil
 	}
 
 	instance.Status.RemoveCondition(appv1.ConditionReleaseFailed)
@@ -378,7 +409,7 @@ func (r *ReconcileHelmRelease) install(instance *appv1.HelmRelease, manager helm
 	controllerutil.AddFinalizer(instance, finalizer)
 	if err := r.updateResource(instance); err != nil {
 		klog.Error("Failed to add uninstall finalizer to ", helmreleaseNsn(instance), " ", err)
-		return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+		return r.releaseRetry(), nil
 	}
 
 	klog.Info("Installed HelmRelease ", helmreleaseNsn(instance))
@@ -393,10 +424,7 @@ func (r *ReconcileHelmRelease) install(instance *appv1.HelmRelease, manager helm
 		Reason:  appv1.ReasonInstallSuccessful,
 		Message: message,
 	})
-	instance.Status.DeployedRelease = &appv1.HelmAppRelease{
-		Name:     installedRelease.Name,
-		Manifest: installedRelease.Manifest,
-	}
+	instance.Status.DeployedRelease = deployedReleaseStatus(installedRelease)
 	err = r.updateResourceStatus(instance)
 	if err != nil {
 		klog.Error("Failed to update resource status for HelmRelease ",
@@ -406,7 +434,95 @@ func (r *ReconcileHelmRelease) install(instance *appv1.HelmRelease, manager helm
 	return reconcile.Result{}, err
 }
 
+// takeOwnership adopts the pre-existing objects rendered by the chart when the HelmRelease asks for it. It runs
+// before the install only, the objects of a deployed release are owned already.
+func (r *ReconcileHelmRelease) takeOwnership(instance *appv1.HelmRelease, manager helmoperator.Manager) error {
+	if !instance.Install.AdoptsObjects() {
+		return nil
+	}
+
+	err := manager.TakeOwnership(context.TODO())
+	if err != nil {
+		klog.Error("Failed to take ownership of existing resources for HelmRelease ",
+			helmreleaseNsn(instance), " ", err)
+		instance.Status.SetCondition(appv1.HelmAppCondition{
+			Type:    appv1.ConditionReleaseFailed,
+			Status:  appv1.StatusTrue,
+			Reason:  appv1.ReasonAdoptionError,
+			Message: err.Error(),
+		})
+		_ = r.updateResourceStatus(instance)
+	}
+
+	return err
+}
+
+// validateValues validates the values against the values.schema.json of the chart. The invalid values are listed
+// in the ValuesInvalid condition and fail the release.
+func (r *ReconcileHelmRelease) validateValues(instance *appv1.HelmRelease, manager helmoperator.Manager) error {
+	err := manager.ValidateValues(instance.Validation.RejectsUnknownValues())
+	if err == nil {
+		instance.Status.RemoveCondition(appv1.ConditionValuesInvalid)
+		return nil
+	}
+
+	klog.Error("Failed to validate the values of HelmRelease ", helmreleaseNsn(instance), " ", err)
+
+	reason := appv1.ReasonReconcileError
+
+	var invalid *helmoperator.ValuesInvalidError
+	if errors.As(err, &invalid) {
+		reason = appv1.ReasonValuesInvalid
+
+		instance.Status.SetCondition(appv1.HelmAppCondition{
+			Type:    appv1.ConditionValuesInvalid,
+			Status:  appv1.StatusTrue,
+			Reason:  appv1.ReasonValuesInvalid,
+			Message: err.Error(),
+		})
+	}
+
+	instance.Status.SetCondition(appv1.HelmAppCondition{
+		Type:    appv1.ConditionReleaseFailed,
+		Status:  appv1.StatusTrue,
+		Reason:  reason,
+		Message: err.Error(),
+	})
+	_ = r.updateResourceStatus(instance)
+
+	return err
+}
+
+// dryRunUpgrade validates the manifests of the upgrade with a server-side dry-run when the HelmRelease asks for it
+func (r *ReconcileHelmRelease) dryRunUpgrade(instance *appv1.HelmRelease, manager helmoperator.Manager) error {
+	if !instance.Upgrade.ServerDryRuns() {
+		return nil
+	}
+
+	err := manager.DryRunUpgrade(context.TODO())
+	if err != nil {
+		klog.Error("Failed the server-side dry-run of the upgrade of HelmRelease ", helmreleaseNsn(instance), " ", err)
+		instance.Status.SetCondition(appv1.HelmAppCondition{
+			Type:    appv1.ConditionReleaseFailed,
+			Status:  appv1.StatusTrue,
+			Reason:  appv1.ReasonDryRunFailed,
+			Message: err.Error(),
+		})
+		_ = r.updateResourceStatus(instance)
+	}
+
+	return err
+}
+
 func (r *ReconcileHelmRelease) upgrade(instance *appv1.HelmRelease, manager helmoperator.Manager) (reconcile.Result, error) {
+	if err := r.checkPolicies(instance, manager); err != nil {
+		return r.releaseRetry(), nil
+	}
+
+	if err := r.dryRunUpgrade(instance, manager); err != nil {
+		return r.releaseRetry(), nil
+	}
+
 	klog.Info("Upgrading Release ", helmreleaseNsn(instance))
 
 	force := hasHelmUpgradeForceAnnotation(instance)
@@ -426,7 +542,7 @@ func (r *ReconcileHelmRelease) upgrade(instance *appv1.HelmRelease, manager helm
 		if errRemoveCRDs := r.hackMultiClusterHubRemoveCRDReferences(instance, manager.GetActionConfig()); errRemoveCRDs != nil {
 			klog.Error("Failed to hackMultiClusterHubRemoveCRDReferences: ", errRemoveCRDs)
 
-			return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+			return r.releaseRetry(), nil
 		}
 
 		if upgradedRelease != nil {
@@ -446,13 +562,13 @@ func (r *ReconcileHelmRelease) upgrade(instance *appv1.HelmRelease, manager helm
 				})
 				_ = r.updateResourceStatus(instance)
 
-				return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+				return r.releaseRetry(), nil
 			}
 
 			klog.Info("Rollbacked Release for upgrade failure ", helmreleaseNsn(instance))
 		}
 
-		return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+		return r.releaseRetry(), nil
 	}
 	instance.Status.RemoveCondition(appv1.ConditionReleaseFailed)
 
@@ -467,10 +583,7 @@ func (r *ReconcileHelmRelease) upgrade(instance *appv1.HelmRelease, manager helm
 		Reason:  appv1.ReasonUpgradeSuccessful,
 		Message: message,
 	})
-	instance.Status.DeployedRelease = &appv1.HelmAppRelease{
-		Name:     upgradedRelease.Name,
-		Manifest: upgradedRelease.Manifest,
-	}
+	instance.Status.DeployedRelease = deployedReleaseStatus(upgradedRelease)
 	err = r.updateResourceStatus(instance)
 	if err != nil {
 		klog.Error("Failed to update resource status for HelmRelease ",
@@ -487,17 +600,29 @@ func (r *ReconcileHelmRelease) uninstall(instance *appv1.HelmRelease, manager he
 		return reconcile.Result{}, nil
 	}
 
+	if policy := instance.Uninstall.GetDeletionPolicy(); policy != appv1.DeletionPolicyDelete {
+		return r.orphan(instance, manager, policy == appv1.DeletionPolicyKeepReleaseRecord)
+	}
+
 	klog.Info("Uninstalling Release ", helmreleaseNsn(instance))
 
-	_, err := manager.UninstallRelease(context.TODO())
+	forceDeadline := instance.Uninstall.ForceDeadline(instance.GetDeletionTimestamp())
+	forced := forceDeadline != nil && !time.Now().Before(forceDeadline.Time)
+
+	_, err := manager.UninstallRelease(context.TODO(), uninstallOptions(instance)...)
 	if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
 		klog.Error("Failed to uninstall HelmRelease ", helmreleaseNsn(instance), " ", err)
 		r.updateUninstallResourceErrorStatus(instance, err)
 
-		return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
-	}
+		if !forced {
+			return requeueUntil(r.releaseRetry().RequeueAfter, forceDeadline), nil
+		}
 
-	klog.Info("Uninstalled HelmRelease ", helmreleaseNsn(instance))
+		klog.Warning("Uninstall force deadline passed for HelmRelease ", helmreleaseNsn(instance),
+			", checking for leftover resources")
+	} else {
+		klog.Info("Uninstalled HelmRelease ", helmreleaseNsn(instance))
+	}
 
 	// no need to check for remaining resources when there is no DeployedRelease
 	// skip ahead to removing the finalizer and let the helmrelease terminate
@@ -507,12 +632,12 @@ func (r *ReconcileHelmRelease) uninstall(instance *appv1.HelmRelease, manager he
 		if err := r.updateResource(instance); err != nil {
 			klog.Error("Failed to strip HelmRelease uninstall finalizer ", helmreleaseNsn(instance), " ", err)
 
-			return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+			return r.releaseRetry(), nil
 		}
 
 		klog.Info("Removed finalizer from HelmRelease ", helmreleaseNsn(instance), " requeue after 1 minute")
 
-		return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+		return r.releaseRetry(), nil
 	}
 
 	klog.Info("Checking to see if all the resources in Status.DeployedRelease.Manifest are deleted ",
@@ -523,9 +648,9 @@ func (r *ReconcileHelmRelease) uninstall(instance *appv1.HelmRelease, manager he
 	caps, err := GetCapabilities(manager.GetActionConfig())
 	if err != nil {
 		klog.Error("Failed to get API Capabilities to perform cleanup check ", helmreleaseNsn(instance), " ", err)
-		r.updateUninstallResourceErrorStatus(instance, err)
 
-		return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+		return r.uninstallCheckFailed(instance, forced, forceDeadline, nil,
+			manifestResourceNames(instance.Status.DeployedRelease.Manifest), err)
 	}
 
 	manifests := releaseutil.SplitManifests(instance.Status.DeployedRelease.Manifest)
@@ -533,9 +658,9 @@ func (r *ReconcileHelmRelease) uninstall(instance *appv1.HelmRelease, manager he
 	_, files, err := releaseutil.SortManifests(manifests, caps.APIVersions, releaseutil.UninstallOrder)
 	if err != nil {
 		klog.Error("Corrupted release record for ", helmreleaseNsn(instance), " ", err)
-		r.updateUninstallResourceErrorStatus(instance, err)
 
-		return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+		return r.uninstallCheckFailed(instance, forced, forceDeadline, nil,
+			manifestResourceNames(instance.Status.DeployedRelease.Manifest), err)
 	}
 
 	// do not delete resources that are annotated with the Helm resource policy 'keep'
@@ -547,50 +672,67 @@ func (r *ReconcileHelmRelease) uninstall(instance *appv1.HelmRelease, manager he
 	resources, err := manager.GetActionConfig().KubeClient.Build(strings.NewReader(builder.String()), false)
 	if err != nil {
 		klog.Error("Unable to build kubernetes objects for delete ", helmreleaseNsn(instance), " ", err)
-		r.updateUninstallResourceErrorStatus(instance, err)
 
-		return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+		return r.uninstallCheckFailed(instance, forced, forceDeadline, nil, manifestResourceNames(builder.String()), err)
 	}
 
-	if len(resources) > 0 {
-		for _, resource := range resources {
-			err = resource.Get()
-			if err != nil {
-				if apierrors.IsNotFound(err) {
-					continue // resource is already delete, check the next one.
-				}
-				klog.Error("Unable to get resource ", resource.Namespace, "/", resource.Name,
-					" for ", helmreleaseNsn(instance), " ", err)
-				r.updateUninstallResourceErrorStatus(instance, err)
+	leftovers := []string{}
+	unchecked := []string{}
 
-				return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
-			}
+	var checkErr error
 
-			// found at least one resource that is not deleted then just delete everything again.
-			_, errs := manager.GetActionConfig().KubeClient.Delete(resources)
-			if errs != nil {
-				klog.Error("Errors caught while trying to delete resources ", joinErrors(errs))
-			}
+	for _, resource := range resources {
+		gvk := ""
+		if resource.Mapping != nil {
+			gvk = resource.Mapping.GroupVersionKind.String()
+		}
 
-			gvk := ""
-			if resource.Mapping != nil {
-				gvk = resource.Mapping.GroupVersionKind.String()
+		name := gvk + " " + resource.Namespace + "/" + resource.Name
+
+		err = resource.Get()
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue // resource is already delete, check the next one.
 			}
+			klog.Error("Unable to get resource ", resource.Namespace, "/", resource.Name,
+				" for ", helmreleaseNsn(instance), " ", err)
 
-			message := "Failed to delete HelmRelease due to resource: " + gvk + " " +
-				resource.Namespace + "/" + resource.Name +
-				" is not deleted yet. Checking again after one minute."
-			klog.Error(message)
-			instance.Status.SetCondition(appv1.HelmAppCondition{
-				Type:    appv1.ConditionReleaseFailed,
-				Status:  appv1.StatusTrue,
-				Reason:  appv1.ReasonUninstallError,
-				Message: message,
-			})
-			_ = r.updateResourceStatus(instance)
+			unchecked = append(unchecked, name)
+			checkErr = err
+
+			continue
+		}
+
+		leftovers = append(leftovers, name)
+	}
+
+	if checkErr != nil {
+		return r.uninstallCheckFailed(instance, forced, forceDeadline, leftovers, unchecked, checkErr)
+	}
+
+	if len(leftovers) > 0 && forced {
+		return r.forceUninstallFinalizer(instance, leftovers, nil, nil)
+	}
 
-			return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+	if len(leftovers) > 0 {
+		// found at least one resource that is not deleted then just delete everything again.
+		_, errs := manager.GetActionConfig().KubeClient.Delete(resources)
+		if errs != nil {
+			klog.Error("Errors caught while trying to delete resources ", joinErrors(errs))
 		}
+
+		message := "Failed to delete HelmRelease due to resource: " + leftovers[0] +
+			" is not deleted yet. Checking again after one minute."
+		klog.Error(message)
+		instance.Status.SetCondition(appv1.HelmAppCondition{
+			Type:    appv1.ConditionReleaseFailed,
+			Status:  appv1.StatusTrue,
+			Reason:  appv1.ReasonUninstallError,
+			Message: message,
+		})
+		_ = r.updateResourceStatus(instance)
+
+		return requeueUntil(r.releaseRetry().RequeueAfter, forceDeadline), nil
 	}
 
 	klog.Info("HelmRelease ", helmreleaseNsn(instance),
@@ -604,18 +746,161 @@ func (r *ReconcileHelmRelease) uninstall(instance *appv1.HelmRelease, manager he
 	})
 	_ = r.updateResourceStatus(instance)
 
+	return r.removeUninstallFinalizer(instance)
+}
+
+// uninstallCheckFailed retries the check of the leftover resources of the uninstall until the force deadline, then
+// forces the finalization, the check can keep failing once the CRDs of the release are deleted
+func (r *ReconcileHelmRelease) uninstallCheckFailed(instance *appv1.HelmRelease, forced bool,
+	forceDeadline *metav1.Time, leftovers, unchecked []string, err error) (reconcile.Result, error) {
+	if forced {
+		return r.forceUninstallFinalizer(instance, leftovers, unchecked, err)
+	}
+
+	r.updateUninstallResourceErrorStatus(instance, err)
+
+	return requeueUntil(r.releaseRetry().RequeueAfter, forceDeadline), nil
+}
+
+// forceUninstallFinalizer removes the finalizer once the force deadline passed, with an event that lists the
+// resources that are not deleted and those that could not be checked
+func (r *ReconcileHelmRelease) forceUninstallFinalizer(instance *appv1.HelmRelease,
+	leftovers, unchecked []string, err error) (reconcile.Result, error) {
+	message := "Forced the finalization of HelmRelease after " + instance.Uninstall.ForceAfter.Duration.String()
+
+	if len(leftovers) > 0 {
+		message += ", the following resources are not deleted: " + strings.Join(leftovers, ", ")
+	}
+
+	if err != nil {
+		message += ", the following resources could not be checked: " + strings.Join(unchecked, ", ") + ": " + err.Error()
+	}
+
+	klog.Warning(message)
+	r.GetEventRecorderFor(controllerName).Event(instance, corev1.EventTypeWarning, string(appv1.ReasonUninstallForced), message)
+
+	instance.Status.RemoveCondition(appv1.ConditionReleaseFailed)
+	instance.Status.SetCondition(appv1.HelmAppCondition{
+		Type:    appv1.ConditionDeployed,
+		Status:  appv1.StatusFalse,
+		Reason:  appv1.ReasonUninstallForced,
+		Message: message,
+	})
+	_ = r.updateResourceStatus(instance)
+
+	return r.removeUninstallFinalizer(instance)
+}
+
+// manifestResourceNames returns the group, version, kind, namespace and name of the objects of the manifest
+func manifestResourceNames(manifest string) []string {
+	manifests := releaseutil.SplitManifests(manifest)
+
+	keys := []string{}
+	for key := range manifests {
+		keys = append(keys, key)
+	}
+
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	names := []string{}
+
+	for _, key := range keys {
+		content := manifests[key]
+
+		var obj struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+			Metadata   struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		}
+
+		if err := yaml.Unmarshal([]byte(content), &obj); err != nil || obj.Kind == "" {
+			continue
+		}
+
+		gvk := schema.FromAPIVersionAndKind(obj.APIVersion, obj.Kind)
+		names = append(names, gvk.String()+" "+obj.Metadata.Namespace+"/"+obj.Metadata.Name)
+	}
+
+	return names
+}
+
+func (r *ReconcileHelmRelease) removeUninstallFinalizer(instance *appv1.HelmRelease) (reconcile.Result, error) {
 	controllerutil.RemoveFinalizer(instance, finalizer)
 
 	if err := r.updateResource(instance); err != nil {
 		klog.Error("Failed to strip HelmRelease uninstall finalizer ",
 			helmreleaseNsn(instance), " ", err)
 
-		return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+		return r.releaseRetry(), nil
 	}
 
 	// if everything goes well the next time the reconcile won't find the helmrelease anymore
 	// which will end the reconcile loop
-	return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+	return r.releaseRetry(), nil
+}
+
+// uninstallOptions returns the Helm uninstall options from the HelmRelease uninstall settings
+func uninstallOptions(instance *appv1.HelmRelease) []release.UninstallOption {
+	if instance.Uninstall == nil {
+		return nil
+	}
+
+	opts := []release.UninstallOption{
+		release.UninstallWait(instance.Uninstall.Wait),
+		release.KeepHistory(instance.Uninstall.KeepHistory),
+	}
+
+	if instance.Uninstall.Timeout != nil {
+		opts = append(opts, release.UninstallTimeout(instance.Uninstall.Timeout.Duration))
+	}
+
+	return opts
+}
+
+// requeueUntil requeues after the interval, or at the force deadline if it comes first
+func requeueUntil(interval time.Duration, deadline *metav1.Time) reconcile.Result {
+	requeueAfter := interval
+
+	if deadline != nil {
+		if untilDeadline := time.Until(deadline.Time); untilDeadline < requeueAfter {
+			requeueAfter = untilDeadline
+		}
+
+		if requeueAfter <= 0 {
+			requeueAfter = time.Second
+		}
+	}
+
+	return reconcile.Result{RequeueAfter: requeueAfter}
+}
+
+// orphan detaches the release resources from the HelmRelease and lets the HelmRelease terminate
+// without deleting them
+func (r *ReconcileHelmRelease) orphan(instance *appv1.HelmRelease, manager helmoperator.Manager,
+	keepReleaseRecords bool) (reconcile.Result, error) {
+	klog.Info("Orphaning Release ", helmreleaseNsn(instance), " keepReleaseRecords=", keepReleaseRecords)
+
+	if err := manager.OrphanRelease(context.TODO(), keepReleaseRecords); err != nil {
+		klog.Error("Failed to orphan HelmRelease ", helmreleaseNsn(instance), " ", err)
+		r.updateUninstallResourceErrorStatus(instance, err)
+
+		return r.releaseRetry(), nil
+	}
+
+	instance.Status.RemoveCondition(appv1.ConditionReleaseFailed)
+	instance.Status.SetCondition(appv1.HelmAppCondition{
+		Type:   appv1.ConditionDeployed,
+		Status: appv1.StatusFalse,
+		Reason: appv1.ReasonOrphanSuccessful,
+	})
+	_ = r.updateResourceStatus(instance)
+
+	klog.Info("Orphaned Release ", helmreleaseNsn(instance))
+
+	return r.removeUninstallFinalizer(instance)
 }
 
 func (r *ReconcileHelmRelease) updateUninstallResourceErrorStatus(instance *appv1.HelmRelease, err error) {
@@ -641,7 +926,7 @@ func (r *ReconcileHelmRelease) ensureStatusReasonPopulated(
 			Message: err.Error(),
 		})
 		_ = r.updateResourceStatus(instance)
-		return reconcile.Result{RequeueAfter: time.Minute * 1}, nil
+		return r.releaseRetry(), nil
 	}
 	instance.Status.RemoveCondition(appv1.ConditionIrreconcilable)
 
@@ -659,10 +944,7 @@ func (r *ReconcileHelmRelease) ensureStatusReasonPopulated(
 		Reason:  reason,
 		Message: message,
 	})
-	instance.Status.DeployedRelease = &appv1.HelmAppRelease{
-		Name:     expectedRelease.Name,
-		Manifest: expectedRelease.Manifest,
-	}
+	instance.Status.DeployedRelease = deployedReleaseStatus(expectedRelease)
 	err = r.updateResourceStatus(instance)
 	if err != nil {
 		klog.Error("Failed to update resource status for HelmRelease ",
@@ -672,6 +954,23 @@ func (r *ReconcileHelmRelease) ensureStatusReasonPopulated(
 	return reconcile.Result{}, err
 }
 
+// deployedReleaseStatus returns the status of the deployed release rel
+func deployedReleaseStatus(rel *rpb.Release) *appv1.HelmAppRelease {
+	deployed := &appv1.HelmAppRelease{
+		Name:     rel.Name,
+		Manifest: rel.Manifest,
+		Revision: rel.Version,
+	}
+
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		deployed.ChartName = rel.Chart.Metadata.Name
+		deployed.ChartVersion = rel.Chart.Metadata.Version
+		deployed.AppVersion = rel.Chart.Metadata.AppVersion
+	}
+
+	return deployed
+}
+
 func helmreleaseNsn(hr *appv1.HelmRelease) string {
 	return fmt.Sprintf("%s/%s", hr.GetNamespace(), hr.GetName())
 }
@@ -21,14 +21,20 @@ import (
 	"github.com/ghodss/yaml"
 	"github.com/onsi/gomega"
 	"golang.org/x/net/context"
+	rpb "helm.sh/helm/v3/pkg/release"
 	v1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	helmoperator "github.com/stolostron/multicloud-operators-subscription-release/pkg/release"
 )
 
 var (
@@ -54,7 +60,7 @@ func TestReconcile(t *testing.T) {
 	c := mgr.GetClient()
 
 	rec := &ReconcileHelmRelease{
-		mgr,
+		Manager: mgr,
 	}
 
 	t.Log("Setup test reconcile")
@@ -115,8 +121,9 @@ func TestReconcile(t *testing.T) {
 	instanceResp.Status.RemoveCondition(appv1.ConditionInitialized)
 	g.Expect(instanceResp.Status.Conditions[0].Reason).To(gomega.Equal(appv1.ReasonInstallSuccessful))
 
-	// remove the deployed condition (InstallSuccessful)
+	// remove the deployed condition (InstallSuccessful) and the computed ready condition
 	instanceResp.Status.RemoveCondition(appv1.ConditionDeployed)
+	instanceResp.Status.RemoveCondition(appv1.ConditionReady)
 
 	err = c.Status().Update(context.TODO(), instanceResp)
 	g.Expect(err).NotTo(gomega.HaveOccurred())
@@ -141,7 +148,7 @@ func TestReconcile(t *testing.T) {
 	instanceResp = &appv1.HelmRelease{}
 	err = c.Get(context.TODO(), helmReleaseKey, instanceResp)
 	g.Expect(err).NotTo(gomega.HaveOccurred())
-	g.Expect(len(instanceResp.Status.Conditions)).To(gomega.Equal(2))
+	g.Expect(len(instanceResp.Status.Conditions)).To(gomega.Equal(3))
 	g.Expect(instanceResp.Status.Conditions[1].Reason).To(gomega.Equal(appv1.ReasonInstallSuccessful))
 
 	// trigger a update
@@ -162,8 +169,9 @@ func TestReconcile(t *testing.T) {
 	instanceResp.Status.RemoveCondition(appv1.ConditionInitialized)
 	g.Expect(instanceResp.Status.Conditions[0].Reason).To(gomega.Equal(appv1.ReasonUpgradeSuccessful))
 
-	// remove the deployed condition (UpdateSuccessful)
+	// remove the deployed condition (UpdateSuccessful) and the computed ready condition
 	instanceResp.Status.RemoveCondition(appv1.ConditionDeployed)
+	instanceResp.Status.RemoveCondition(appv1.ConditionReady)
 
 	err = c.Status().Update(context.TODO(), instanceResp)
 	g.Expect(err).NotTo(gomega.HaveOccurred())
@@ -188,7 +196,7 @@ func TestReconcile(t *testing.T) {
 	instanceResp = &appv1.HelmRelease{}
 	err = c.Get(context.TODO(), helmReleaseKey, instanceResp)
 	g.Expect(err).NotTo(gomega.HaveOccurred())
-	g.Expect(len(instanceResp.Status.Conditions)).To(gomega.Equal(2))
+	g.Expect(len(instanceResp.Status.Conditions)).To(gomega.Equal(3))
 	g.Expect(instanceResp.Status.Conditions[1].Reason).To(gomega.Equal(appv1.ReasonUpgradeSuccessful))
 
 	instanceResp.Repo.Source.GitHub.Urls[0] = "https://github.com/stolostron/multicloud-operators-subscription-release-wrongurl.git"
@@ -937,3 +945,323 @@ func Test_generateResourceListForHelm(t *testing.T) {
 	g.Expect(err).NotTo(gomega.HaveOccurred())
 	g.Expect(resourceList).NotTo(gomega.BeNil())
 }
+
+func TestRequeueUntil(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(requeueUntil(time.Minute, nil).RequeueAfter).To(gomega.Equal(time.Minute))
+
+	later := metav1.NewTime(time.Now().Add(time.Hour))
+	g.Expect(requeueUntil(time.Minute, &later).RequeueAfter).To(gomega.Equal(time.Minute))
+
+	soon := metav1.NewTime(time.Now().Add(10 * time.Second))
+	g.Expect(requeueUntil(time.Minute, &soon).RequeueAfter).To(gomega.BeNumerically("<=", 10*time.Second))
+
+	passed := metav1.NewTime(time.Now().Add(-time.Minute))
+	g.Expect(requeueUntil(time.Minute, &passed).RequeueAfter).To(gomega.Equal(time.Second))
+
+	deletion := metav1.NewTime(time.Now())
+	uninstall := &appv1.HelmReleaseUninstall{ForceAfter: &metav1.Duration{Duration: time.Hour}}
+	g.Expect(uninstall.ForceDeadline(&deletion).Time).To(gomega.Equal(deletion.Add(time.Hour)))
+	g.Expect((&appv1.HelmReleaseUninstall{}).ForceDeadline(&deletion)).To(gomega.BeNil())
+}
+
+func TestManifestResourceNames(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	manifest := `---
+# Source: chart/templates/widget.yaml
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: first
+  namespace: apps
+---
+# Source: chart/templates/role.yaml
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+---
+# Source: chart/templates/empty.yaml
+`
+
+	g.Expect(manifestResourceNames(manifest)).To(gomega.Equal([]string{
+		"example.com/v1, Kind=Widget apps/first",
+		"rbac.authorization.k8s.io/v1, Kind=ClusterRole /reader",
+	}))
+}
+
+// clientManager is a controller manager that only provides a client
+type clientManager struct {
+	manager.Manager
+	client client.Client
+}
+
+func (m clientManager) GetClient() client.Client {
+	return m.client
+}
+
+// orphanManager is a release manager that only orphans the release
+type orphanManager struct {
+	helmoperator.Manager
+	keepReleaseRecords *bool
+}
+
+func (m orphanManager) OrphanRelease(_ context.Context, keepReleaseRecords bool) error {
+	*m.keepReleaseRecords = keepReleaseRecords
+
+	return nil
+}
+
+func TestUninstallOrphan(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(appv1.SchemeBuilder.AddToScheme(scheme)).To(gomega.Succeed())
+
+	for _, policy := range []appv1.DeletionPolicy{appv1.DeletionPolicyOrphan, appv1.DeletionPolicyKeepReleaseRecord} {
+		deletion := metav1.Now()
+		instance := &appv1.HelmRelease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "orphan",
+				Namespace:         "default",
+				Finalizers:        []string{finalizer},
+				DeletionTimestamp: &deletion,
+			},
+			Uninstall: &appv1.HelmReleaseUninstall{DeletionPolicy: policy},
+		}
+
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(instance).Build()
+		r := &ReconcileHelmRelease{Manager: clientManager{client: c}}
+
+		key := types.NamespacedName{Name: "orphan", Namespace: "default"}
+		g.Expect(c.Get(context.TODO(), key, instance)).To(gomega.Succeed())
+
+		keepReleaseRecords := new(bool)
+		_, err := r.uninstall(instance, orphanManager{keepReleaseRecords: keepReleaseRecords})
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(*keepReleaseRecords).To(gomega.Equal(policy == appv1.DeletionPolicyKeepReleaseRecord))
+
+		// the HelmRelease terminates once its finalizer is removed
+		err = c.Get(context.TODO(), key, &appv1.HelmRelease{})
+		g.Expect(apierrors.IsNotFound(err)).To(gomega.BeTrue())
+		g.Expect(instance.GetFinalizers()).To(gomega.BeEmpty())
+
+		deployed := instance.Status.GetCondition(appv1.ConditionDeployed)
+		g.Expect(deployed.Status).To(gomega.Equal(appv1.StatusFalse))
+		g.Expect(deployed.Reason).To(gomega.Equal(appv1.ReasonOrphanSuccessful))
+	}
+}
+
+func TestOrderSources(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "sources", Namespace: "default"},
+		Repo: appv1.HelmReleaseRepo{
+			Source: &appv1.Source{
+				SourceType: appv1.HelmRepoSourceType,
+				HelmRepo:   &appv1.HelmRepo{Urls: []string{"https://primary.example.com/chart.tgz"}},
+			},
+			Sources: []appv1.AltSource{
+				{Name: "mirror", SourceType: appv1.HelmRepoSourceType},
+				{SourceType: appv1.GitSourceType},
+			},
+		},
+	}
+
+	names := func(sources []appv1.AltSource) []string {
+		n := []string{}
+		for _, s := range sources {
+			n = append(n, s.Name)
+		}
+
+		return n
+	}
+
+	health := &sourceHealth{failures: map[string]time.Time{}}
+	now := time.Now()
+
+	health.failed(sourceHealthKey(hr, appv1.AltSource{Name: "source"}), now)
+
+	// FirstSuccess ignores the health of the sources
+	g.Expect(names(health.orderSources(hr, now))).To(gomega.Equal([]string{"source", "mirror", "sources[1]"}))
+
+	hr.Repo.FailoverPolicy = appv1.FailoverPolicyPreferred
+	g.Expect(names(health.orderSources(hr, now))).To(gomega.Equal([]string{"mirror", "sources[1]", "source"}))
+
+	// the preferred source is tried first again once it is due for a retry
+	later := now.Add(unhealthySourceRetryInterval)
+	g.Expect(names(health.orderSources(hr, later))).To(gomega.Equal([]string{"source", "mirror", "sources[1]"}))
+
+	health.succeeded(sourceHealthKey(hr, appv1.AltSource{Name: "source"}))
+	g.Expect(names(health.orderSources(hr, now))).To(gomega.Equal([]string{"source", "mirror", "sources[1]"}))
+
+	// the expired failures and the failures of the deleted HelmReleases are dropped
+	other := &appv1.HelmRelease{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"}}
+
+	health.failed(sourceHealthKey(other, appv1.AltSource{Name: "removed"}), now)
+	health.orderSources(hr, later)
+	g.Expect(health.failures).To(gomega.BeEmpty())
+
+	health.failed(sourceHealthKey(hr, appv1.AltSource{Name: "mirror"}), now)
+	health.failed(sourceHealthKey(other, appv1.AltSource{Name: "source"}), now)
+	health.forget(hr)
+	g.Expect(health.failures).To(gomega.HaveLen(1))
+	g.Expect(health.failures).To(gomega.HaveKey(sourceHealthKey(other, appv1.AltSource{Name: "source"})))
+}
+
+func TestVerifiedFailover(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	unverified := appv1.AltSource{Name: "unverified", SourceType: appv1.HelmRepoSourceType, HelmRepo: &appv1.HelmRepo{}}
+	verified := appv1.AltSource{
+		Name: "verified", SourceType: appv1.HelmRepoSourceType, HelmRepo: &appv1.HelmRepo{Verify: true},
+	}
+	mirror := appv1.AltSource{Name: "mirror", SourceType: appv1.HelmRepoSourceType, HelmRepo: &appv1.HelmRepo{}}
+	signedMirror := appv1.AltSource{
+		Name: "signed-mirror", SourceType: appv1.HelmRepoSourceType, HelmRepo: &appv1.HelmRepo{Verify: true},
+	}
+
+	names := func(sources []appv1.AltSource) []string {
+		n := []string{}
+		for _, s := range sources {
+			n = append(n, s.Name)
+		}
+
+		return n
+	}
+
+	candidates := []appv1.AltSource{unverified, verified, mirror, signedMirror}
+
+	// the sources before the first verified source are kept, after it only the verified ones
+	g.Expect(names(verifiedFailover(candidates, candidates))).To(
+		gomega.Equal([]string{"unverified", "verified", "signed-mirror"}))
+
+	// whatever the order the sources are tried in
+	ordered := []appv1.AltSource{mirror, signedMirror, unverified, verified}
+	g.Expect(names(verifiedFailover(candidates, ordered))).To(
+		gomega.Equal([]string{"signed-mirror", "unverified", "verified"}))
+}
+
+func TestIndexChartObjects(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	hr := &appv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "offline", Namespace: "default"},
+		Repo: appv1.HelmReleaseRepo{
+			Source: &appv1.Source{
+				SourceType: appv1.ConfigMapSourceType,
+				ConfigMap:  &appv1.ConfigMapSource{Name: "chart"},
+			},
+			Sources: []appv1.AltSource{
+				{SourceType: appv1.ConfigMapSourceType, ConfigMap: &appv1.ConfigMapSource{Kind: "Secret", Name: "chart-copy"}},
+			},
+		},
+	}
+
+	g.Expect(indexChartObjects(hr)).To(gomega.Equal([]string{"ConfigMap/chart", "Secret/chart-copy"}))
+	g.Expect(indexChartObjects(&appv1.HelmRelease{})).To(gomega.BeEmpty())
+
+	obj := chartObjectMetadata("Secret")
+	g.Expect(obj.APIVersion).To(gomega.Equal("v1"))
+	g.Expect(obj.Kind).To(gomega.Equal("Secret"))
+}
+
+func TestCheckReleasePolicies(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	rel := &rpb.Release{
+		Manifest: `---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: admin
+  labels:
+    team: apps
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: cluster-admin
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: busybox
+      containers:
+      - name: web
+        image: registry.example.com/apps/web:1.0
+        securityContext:
+          privileged: true
+`,
+		Hooks: []*rpb.Hook{{Manifest: `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  labels:
+    team: apps
+spec:
+  template:
+    spec:
+      containers:
+      - name: migrate
+        image: quay.io/other/migrate
+`}},
+	}
+
+	policies := []namedPolicy{
+		{name: "default/rbac", policy: manifestPolicy{ForbiddenRoles: []string{"cluster-admin"}}},
+		{name: "default/pods", policy: manifestPolicy{
+			RequiredLabels:    []string{"team"},
+			AllowedRegistries: []string{"registry.example.com", "docker.io/library/"},
+			ForbidPrivileged:  true,
+		}},
+	}
+
+	err := checkReleasePolicies(rel, policies)
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(err.(*policyViolationError).violations).To(gomega.Equal([]string{
+		"ClusterRoleBinding admin: binds forbidden role cluster-admin (policy default/rbac)",
+		"Deployment default/web: container web is privileged (policy default/pods)",
+		"Deployment default/web: missing required label team (policy default/pods)",
+		"Job migrate: image quay.io/other/migrate of container migrate is not from an allowed registry (policy default/pods)",
+	}))
+
+	g.Expect(checkReleasePolicies(rel, []namedPolicy{
+		{name: "default/kinds", policy: manifestPolicy{ForbiddenKinds: []string{"ConfigMap", "apps/DaemonSet"}}},
+	})).To(gomega.Succeed())
+	g.Expect(checkReleasePolicies(rel, []namedPolicy{
+		{name: "default/kinds", policy: manifestPolicy{ForbiddenKinds: []string{"rbac.authorization.k8s.io/ClusterRoleBinding"}}},
+	})).NotTo(gomega.Succeed())
+}
+
+func TestOptions(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	options := Options{MaxConcurrentReconciles: 50, ReleaseRetryInterval: 10 * time.Minute}.withDefaults()
+	g.Expect(options.MaxConcurrentReconciles).To(gomega.Equal(50))
+	g.Expect(options.DownloadRetryInterval).To(gomega.Equal(time.Minute))
+	g.Expect(options.QPS).To(gomega.Equal(float64(defaultQPS)))
+
+	r := &ReconcileHelmRelease{options: options}
+	g.Expect(r.releaseRetry().RequeueAfter).To(gomega.BeNumerically(">=", 10*time.Minute))
+	g.Expect(r.releaseRetry().RequeueAfter).To(gomega.BeNumerically("<=", 11*time.Minute))
+	g.Expect(r.downloadRetry().RequeueAfter).To(gomega.BeNumerically("<=", 66*time.Second))
+
+	// the per-item backoff doubles on each failure, the token bucket only delays once its burst is used
+	limiter := Options{RetryBaseDelay: time.Second, QPS: 1, Burst: 2}.withDefaults().rateLimiter()
+	g.Expect(limiter.When("a")).To(gomega.Equal(time.Second))
+	g.Expect(limiter.When("a")).To(gomega.Equal(2 * time.Second))
+	g.Expect(limiter.When("b")).To(gomega.BeNumerically(">", 500*time.Millisecond))
+
+	limiter.Forget("a")
+	g.Expect(limiter.NumRequeues("a")).To(gomega.Equal(0))
+}
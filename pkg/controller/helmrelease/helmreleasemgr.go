@@ -20,21 +20,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/ghodss/yaml"
 
+	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
-	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/chartutil"
 
 	helmclient "github.com/stolostron/multicloud-operators-subscription-release/pkg/client"
 	helmoperator "github.com/stolostron/multicloud-operators-subscription-release/pkg/release"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/kube"
-	"helm.sh/helm/v3/pkg/storage/driver"
+	rpb "helm.sh/helm/v3/pkg/release"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/klog"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -51,15 +52,38 @@ func (r ReconcileHelmRelease) newHelmOperatorManagerFactory(
 		return helmoperator.NewManagerFactory(r.Manager, ""), nil
 	}
 
-	chartDir, err := downloadChart(r.GetClient(), s)
+	chart, err := downloadChart(r.GetClient(), s)
 	if err != nil {
 		klog.Error(err, " - Failed to download the chart")
+
+		if errors.Is(err, utils.ErrChartNotVerified) {
+			s.Status.SetCondition(appv1.HelmAppCondition{
+				Type:    appv1.ConditionVerified,
+				Status:  appv1.StatusFalse,
+				Reason:  appv1.ReasonVerificationFailed,
+				Message: err.Error(),
+			})
+		}
+
 		return nil, err
 	}
 
-	klog.V(3).Info("ChartDir: ", chartDir)
+	klog.V(3).Info("ChartDir: ", chart.Dir)
+
+	s.Status.ChartSource = utils.ChartSourceStatus(s.Repo.Source, chart)
 
-	f := helmoperator.NewManagerFactory(r.Manager, chartDir)
+	if utils.SourceVerifiesSignature(s.Repo.Source) {
+		s.Status.SetCondition(appv1.HelmAppCondition{
+			Type:    appv1.ConditionVerified,
+			Status:  appv1.StatusTrue,
+			Reason:  appv1.ReasonChartVerified,
+			Message: "signed by " + chart.SignedBy,
+		})
+	} else {
+		s.Status.RemoveCondition(appv1.ConditionVerified)
+	}
+
+	f := helmoperator.NewManagerFactory(r.Manager, chart.Dir)
 
 	return f, nil
 }
@@ -88,17 +112,17 @@ func (r ReconcileHelmRelease) newHelmOperatorManager(
 }
 
 //downloadChart downloads the chart
-func downloadChart(client client.Client, s *appv1.HelmRelease) (string, error) {
+func downloadChart(client client.Client, s *appv1.HelmRelease) (*utils.Chart, error) {
 	configMap, err := utils.GetConfigMap(client, s.Namespace, s.Repo.ConfigMapRef)
 	if err != nil {
 		klog.Error(err)
-		return "", err
+		return nil, err
 	}
 
 	secret, err := utils.GetSecret(client, s.Namespace, s.Repo.SecretRef)
 	if err != nil {
 		klog.Error(err, " - Failed to retrieve secret ", s.Repo.SecretRef.Name)
-		return "", err
+		return nil, err
 	}
 
 	chartsDir := os.Getenv(appv1.ChartsDir)
@@ -106,20 +130,35 @@ func downloadChart(client client.Client, s *appv1.HelmRelease) (string, error) {
 		chartsDir = "/tmp/hr-charts"
 	}
 
-	chartDir, err := utils.DownloadChart(configMap, secret, chartsDir, s)
-	klog.V(3).Info("ChartDir: ", chartDir)
-
+	chart, err := utils.FetchChartWithClient(client, configMap, secret, chartsDir, s)
 	if err != nil {
 		klog.Error(err, " - Failed to download the chart")
-		return "", err
+		return nil, err
 	}
 
-	return chartDir, nil
+	klog.V(3).Info("ChartDir: ", chart.Dir)
+
+	return chart, nil
 }
 
-//generateResourceList generates the resource list for given HelmRelease
-func generateResourceList(mgr manager.Manager, s *appv1.HelmRelease) (kube.ResourceList, error) {
-	chartDir, err := downloadChart(mgr.GetClient(), s)
+// LoadedChart is the chart of a HelmRelease and the values it is rendered with
+type LoadedChart struct {
+	// Dir is the directory of the expanded chart
+	Dir    string
+	Chart  *chart.Chart
+	Values map[string]interface{}
+}
+
+// LoadChart downloads and loads the chart of the HelmRelease like the controller does, from the first of its
+// candidate sources that serves it. The client reads the secrets and config maps of its sources.
+func LoadChart(c client.Client, s *appv1.HelmRelease) (*LoadedChart, error) {
+	var fetched *utils.Chart
+
+	_, err := healthOfSources.fromSources(s, func() (err error) {
+		fetched, err = downloadChart(c, s)
+
+		return err
+	})
 	if err != nil {
 		klog.Error(err, " - Failed to download the chart")
 		return nil, err
@@ -140,47 +179,55 @@ func generateResourceList(mgr manager.Manager, s *appv1.HelmRelease) (kube.Resou
 		return nil, err
 	}
 
-	klog.V(3).Info("ChartDir: ", chartDir)
+	klog.V(3).Info("ChartDir: ", fetched.Dir)
 
-	chart, err := loader.LoadDir(chartDir)
+	crChart, err := loader.LoadDir(fetched.Dir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load chart dir, most likely the given chart name is incorrect: %w", err)
 	}
 
-	clientv1, err := v1.NewForConfig(mgr.GetConfig())
-	if err != nil {
-		return nil, fmt.Errorf("failed to get core/v1 client: %w", err)
-	}
-
-	storageBackend := storage.Init(driver.NewSecrets(clientv1.Secrets(s.GetNamespace())))
-
-	rcg, err := helmclient.NewRESTClientGetter(mgr, s.Namespace)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get REST client getter from manager: %w", err)
-	}
-
-	kubeClient := kube.New(rcg)
-
-	actionConfig := &action.Configuration{
-		RESTClientGetter: rcg,
-		Releases:         storageBackend,
-		KubeClient:       kubeClient,
-		Log:              func(_ string, _ ...interface{}) {},
-	}
+	return &LoadedChart{Dir: fetched.Dir, Chart: crChart, Values: values}, nil
+}
 
-	install := action.NewInstall(actionConfig)
+// Render renders the chart for the HelmRelease with a client-side dry-run of the install. The API versions of the
+// capabilities are added to the default ones of Helm, and their Kubernetes version replaces the default one when set.
+func (l *LoadedChart) Render(s *appv1.HelmRelease, capabilities *chartutil.Capabilities) (*rpb.Release, error) {
+	install := action.NewInstall(&action.Configuration{Log: func(_ string, _ ...interface{}) {}})
 	install.ReleaseName = s.Name
 	install.Namespace = s.Namespace
 	install.DryRun = true
 	install.ClientOnly = true
 	install.Replace = true
 
-	release, err := install.Run(chart, values)
+	if capabilities != nil {
+		if capabilities.KubeVersion.Version != "" {
+			install.KubeVersion = &capabilities.KubeVersion
+		}
+
+		install.APIVersions = capabilities.APIVersions
+	}
+
+	return install.Run(l.Chart, l.Values)
+}
+
+//generateResourceList generates the resource list for given HelmRelease
+func generateResourceList(mgr manager.Manager, s *appv1.HelmRelease) (kube.ResourceList, error) {
+	loaded, err := LoadChart(mgr.GetClient(), s)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := loaded.Render(s, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	resources, err := kubeClient.Build(bytes.NewBufferString(release.Manifest), false)
+	rcg, err := helmclient.NewRESTClientGetter(mgr, s.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get REST client getter from manager: %w", err)
+	}
+
+	resources, err := kube.New(rcg).Build(bytes.NewBufferString(release.Manifest), false)
 	if err != nil {
 		return nil, fmt.Errorf("unable to build kubernetes objects from release manifest: %w", err)
 	}
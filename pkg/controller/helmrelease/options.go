@@ -0,0 +1,121 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	defaultRetryBaseDelay = 5 * time.Millisecond
+	defaultRetryMaxDelay  = 1000 * time.Second
+	defaultQPS            = 10
+	defaultBurst          = 100
+	defaultRetryInterval  = time.Minute
+
+	// requeueJitter spreads the requeues of the HelmReleases that failed together by up to 10% of the interval
+	requeueJitter = 0.1
+)
+
+// Options configures the concurrency, the rate limiting and the retries of the HelmRelease controller
+type Options struct {
+	// MaxConcurrentReconciles is the number of HelmReleases reconciled in parallel
+	MaxConcurrentReconciles int
+	// RetryBaseDelay and RetryMaxDelay bound the per-item exponential backoff of the failed reconciles
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// QPS and Burst configure the token bucket shared by all the items of the work queue
+	QPS   float64
+	Burst int
+	// DownloadRetryInterval is the requeue interval after a failure to fetch the chart
+	DownloadRetryInterval time.Duration
+	// ReleaseRetryInterval is the requeue interval after a failure to install, upgrade, roll back or
+	// uninstall the release
+	ReleaseRetryInterval time.Duration
+}
+
+// DefaultOptions returns the options of the controller when none is configured
+func DefaultOptions() Options {
+	return Options{
+		MaxConcurrentReconciles: defaultMaxConcurrent,
+		RetryBaseDelay:          defaultRetryBaseDelay,
+		RetryMaxDelay:           defaultRetryMaxDelay,
+		QPS:                     defaultQPS,
+		Burst:                   defaultBurst,
+		DownloadRetryInterval:   defaultRetryInterval,
+		ReleaseRetryInterval:    defaultRetryInterval,
+	}
+}
+
+// withDefaults returns the options with the unset fields set to their default
+func (o Options) withDefaults() Options {
+	d := DefaultOptions()
+
+	if o.MaxConcurrentReconciles <= 0 {
+		o.MaxConcurrentReconciles = d.MaxConcurrentReconciles
+	}
+
+	if o.RetryBaseDelay <= 0 {
+		o.RetryBaseDelay = d.RetryBaseDelay
+	}
+
+	if o.RetryMaxDelay <= 0 {
+		o.RetryMaxDelay = d.RetryMaxDelay
+	}
+
+	if o.QPS <= 0 {
+		o.QPS = d.QPS
+	}
+
+	if o.Burst <= 0 {
+		o.Burst = d.Burst
+	}
+
+	if o.DownloadRetryInterval <= 0 {
+		o.DownloadRetryInterval = d.DownloadRetryInterval
+	}
+
+	if o.ReleaseRetryInterval <= 0 {
+		o.ReleaseRetryInterval = d.ReleaseRetryInterval
+	}
+
+	return o
+}
+
+// rateLimiter returns the rate limiter of the work queue, the slowest of the per-item exponential backoff
+// and of the token bucket of the whole queue
+func (o Options) rateLimiter() workqueue.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(o.RetryBaseDelay, o.RetryMaxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(o.QPS), o.Burst)},
+	)
+}
+
+// downloadRetry requeues the HelmRelease after a failure to fetch its chart
+func (r *ReconcileHelmRelease) downloadRetry() reconcile.Result {
+	return reconcile.Result{RequeueAfter: wait.Jitter(r.options.withDefaults().DownloadRetryInterval, requeueJitter)}
+}
+
+// releaseRetry requeues the HelmRelease after a failure of its release
+func (r *ReconcileHelmRelease) releaseRetry() reconcile.Result {
+	return reconcile.Result{RequeueAfter: wait.Jitter(r.options.withDefaults().ReleaseRetryInterval, requeueJitter)}
+}
@@ -0,0 +1,309 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmrelease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	helmoperator "github.com/stolostron/multicloud-operators-subscription-release/pkg/release"
+	"github.com/stolostron/multicloud-operators-subscription-release/pkg/utils"
+)
+
+const (
+	// policyLabel selects the config maps holding the policies of the rendered manifests
+	policyLabel = "apps.open-cluster-management.io/helmrelease-policy"
+	// policyKey is the key of the policy config maps holding the rules
+	policyKey = "policy.yaml"
+	// podNamespaceEnv is the namespace of the operator, its policies apply to every HelmRelease
+	podNamespaceEnv = "POD_NAMESPACE"
+)
+
+// manifestPolicy holds the rules of a policy config map
+type manifestPolicy struct {
+	// ForbiddenKinds are the kinds, as Kind or group/Kind, that the chart can not create
+	ForbiddenKinds []string `json:"forbiddenKinds,omitempty"`
+	// ForbiddenRoles are the roles and cluster roles that the role bindings of the chart can not bind
+	ForbiddenRoles []string `json:"forbiddenRoles,omitempty"`
+	// RequiredLabels are the labels, as key or key=value, that every object of the chart must have
+	RequiredLabels []string `json:"requiredLabels,omitempty"`
+	// AllowedRegistries are the registries, or registry/path prefixes, of the images of the pods
+	AllowedRegistries []string `json:"allowedRegistries,omitempty"`
+	// ForbidPrivileged refuses the privileged containers
+	ForbidPrivileged bool `json:"forbidPrivileged,omitempty"`
+}
+
+// namedPolicy is a policy and the namespace/name of its config map
+type namedPolicy struct {
+	name   string
+	policy manifestPolicy
+}
+
+// policyViolationError lists the policy rules violated by the rendered manifests
+type policyViolationError struct {
+	violations []string
+}
+
+func (e *policyViolationError) Error() string {
+	return fmt.Sprintf("%d policy violations: %s", len(e.violations), strings.Join(e.violations, "; "))
+}
+
+// checkPolicies renders the release and checks its manifests and hooks against the policies of the namespace
+// of the HelmRelease and of the operator. A violation sets the PolicyViolation condition and fails the release.
+func (r *ReconcileHelmRelease) checkPolicies(instance *appv1.HelmRelease, manager helmoperator.Manager) error {
+	policies, err := r.policiesFor(instance.GetNamespace())
+	if err == nil && len(policies) > 0 {
+		var rel *rpb.Release
+
+		rel, err = manager.RenderRelease(context.TODO())
+		if err == nil {
+			err = checkReleasePolicies(rel, policies)
+		}
+	}
+
+	if err == nil {
+		instance.Status.RemoveCondition(appv1.ConditionPolicyViolation)
+		return nil
+	}
+
+	klog.Error("Failed to check the policies of HelmRelease ", helmreleaseNsn(instance), " ", err)
+
+	reason := appv1.ReasonReconcileError
+
+	var violation *policyViolationError
+	if errors.As(err, &violation) {
+		reason = appv1.ReasonPolicyViolation
+
+		instance.Status.SetCondition(appv1.HelmAppCondition{
+			Type:    appv1.ConditionPolicyViolation,
+			Status:  appv1.StatusTrue,
+			Reason:  appv1.ReasonPolicyViolation,
+			Message: err.Error(),
+		})
+	}
+
+	instance.Status.SetCondition(appv1.HelmAppCondition{
+		Type:    appv1.ConditionReleaseFailed,
+		Status:  appv1.StatusTrue,
+		Reason:  reason,
+		Message: err.Error(),
+	})
+	_ = r.updateResourceStatus(instance)
+
+	return err
+}
+
+// policiesFor returns the policies of the config maps labeled with the policy label in the namespace and in
+// the namespace of the operator
+func (r *ReconcileHelmRelease) policiesFor(namespace string) ([]namedPolicy, error) {
+	namespaces := []string{namespace}
+	if ns := os.Getenv(podNamespaceEnv); ns != "" && ns != namespace {
+		namespaces = append(namespaces, ns)
+	}
+
+	policies := []namedPolicy{}
+
+	for _, ns := range namespaces {
+		configMaps := &corev1.ConfigMapList{}
+
+		err := r.GetClient().List(context.TODO(), configMaps, client.InNamespace(ns),
+			client.HasLabels{policyLabel})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the policy config maps of namespace %s: %w", ns, err)
+		}
+
+		for _, cm := range configMaps.Items {
+			name := cm.Namespace + "/" + cm.Name
+
+			p := manifestPolicy{}
+			if err := yaml.UnmarshalStrict([]byte(cm.Data[policyKey]), &p); err != nil {
+				return nil, fmt.Errorf("failed to parse the %s of policy config map %s: %w", policyKey, name, err)
+			}
+
+			policies = append(policies, namedPolicy{name: name, policy: p})
+		}
+	}
+
+	return policies, nil
+}
+
+// checkReleasePolicies checks the manifests and hooks of the release against the policies
+func checkReleasePolicies(rel *rpb.Release, policies []namedPolicy) error {
+	manifests := []string{rel.Manifest}
+	for _, hook := range rel.Hooks {
+		manifests = append(manifests, hook.Manifest)
+	}
+
+	violations := []string{}
+
+	for _, manifest := range manifests {
+		for _, content := range releaseutil.SplitManifests(manifest) {
+			obj := &unstructured.Unstructured{}
+			if err := yaml.Unmarshal([]byte(content), &obj.Object); err != nil {
+				return fmt.Errorf("failed to parse rendered manifest: %w", err)
+			}
+
+			if len(obj.Object) == 0 {
+				continue
+			}
+
+			for _, p := range policies {
+				for _, violation := range p.policy.check(obj) {
+					violations = append(violations, fmt.Sprintf("%s %s: %s (policy %s)", obj.GetKind(),
+						objectName(obj), violation, p.name))
+				}
+			}
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	sort.Strings(violations)
+
+	return &policyViolationError{violations: violations}
+}
+
+// check returns the rules of the policy that the object violates
+func (p manifestPolicy) check(obj *unstructured.Unstructured) []string {
+	violations := []string{}
+	gvk := obj.GroupVersionKind()
+
+	for _, kind := range p.ForbiddenKinds {
+		if matchKind(kind, gvk) {
+			violations = append(violations, "kind "+kind+" is forbidden")
+		}
+	}
+
+	if gvk.Group == "rbac.authorization.k8s.io" && (gvk.Kind == "RoleBinding" || gvk.Kind == "ClusterRoleBinding") {
+		role, _, _ := unstructured.NestedString(obj.Object, "roleRef", "name")
+		if contains(p.ForbiddenRoles, role) {
+			violations = append(violations, "binds forbidden role "+role)
+		}
+	}
+
+	labels := obj.GetLabels()
+
+	for _, label := range p.RequiredLabels {
+		key, value, hasValue := utils.CutString(label, "=")
+		if v, ok := labels[key]; !ok || (hasValue && v != value) {
+			violations = append(violations, "missing required label "+label)
+		}
+	}
+
+	for _, container := range podContainers(obj) {
+		name, _, _ := unstructured.NestedString(container, "name")
+		image, _, _ := unstructured.NestedString(container, "image")
+
+		if len(p.AllowedRegistries) > 0 && !allowedImage(image, p.AllowedRegistries) {
+			violations = append(violations, fmt.Sprintf("image %s of container %s is not from an allowed registry",
+				image, name))
+		}
+
+		if privileged, _, _ := unstructured.NestedBool(container, "securityContext", "privileged"); privileged &&
+			p.ForbidPrivileged {
+			violations = append(violations, "container "+name+" is privileged")
+		}
+	}
+
+	return violations
+}
+
+// matchKind returns true when the Kind or group/Kind matches the group and kind
+func matchKind(kind string, gvk schema.GroupVersionKind) bool {
+	if group, k, ok := utils.CutString(kind, "/"); ok {
+		return group == gvk.Group && k == gvk.Kind
+	}
+
+	return kind == gvk.Kind
+}
+
+// podTemplatePaths are the paths of the pod spec in the objects that run pods
+var podTemplatePaths = map[string][]string{
+	"Pod":         {"spec"},
+	"Deployment":  {"spec", "template", "spec"},
+	"StatefulSet": {"spec", "template", "spec"},
+	"DaemonSet":   {"spec", "template", "spec"},
+	"ReplicaSet":  {"spec", "template", "spec"},
+	"Job":         {"spec", "template", "spec"},
+	"CronJob":     {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// podContainers returns the containers, init containers and ephemeral containers of the pod spec of the object
+func podContainers(obj *unstructured.Unstructured) []map[string]interface{} {
+	podPath, ok := podTemplatePaths[obj.GetKind()]
+	if !ok {
+		return nil
+	}
+
+	containers := []map[string]interface{}{}
+
+	for _, field := range []string{"containers", "initContainers", "ephemeralContainers"} {
+		list, _, _ := unstructured.NestedSlice(obj.Object, append(podPath, field)...)
+		for _, c := range list {
+			if container, ok := c.(map[string]interface{}); ok {
+				containers = append(containers, container)
+			}
+		}
+	}
+
+	return containers
+}
+
+// allowedImage returns true when the image, qualified like docker pull does when it has no registry, starts with
+// one of the registries or registry/path prefixes
+func allowedImage(image string, registries []string) bool {
+	registry, _, hasPath := utils.CutString(image, "/")
+
+	switch {
+	case !hasPath:
+		image = "docker.io/library/" + image
+	case !strings.ContainsAny(registry, ".:") && registry != "localhost":
+		image = "docker.io/" + image
+	}
+
+	for _, allowed := range registries {
+		if strings.HasPrefix(image, strings.TrimSuffix(allowed, "/")+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func objectName(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
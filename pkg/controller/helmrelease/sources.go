@@ -0,0 +1,285 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmrelease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+	helmoperator "github.com/stolostron/multicloud-operators-subscription-release/pkg/release"
+	"github.com/stolostron/multicloud-operators-subscription-release/pkg/utils"
+)
+
+// unhealthySourceRetryInterval is how long a source that failed is tried after the healthy ones
+// with the Preferred failover policy
+const unhealthySourceRetryInterval = 5 * time.Minute
+
+// sourceHealth remembers when the sources of the HelmReleases last failed
+type sourceHealth struct {
+	mu       sync.Mutex
+	failures map[string]time.Time
+}
+
+var healthOfSources = &sourceHealth{failures: map[string]time.Time{}}
+
+func sourceHealthKey(hr *appv1.HelmRelease, source appv1.AltSource) string {
+	return helmreleaseNsn(hr) + "/" + source.Name
+}
+
+func (h *sourceHealth) failed(key string, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.failures[key] = now
+}
+
+func (h *sourceHealth) succeeded(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.failures, key)
+}
+
+// forget drops the failures of the sources of the HelmRelease, once it is deleted
+func (h *sourceHealth) forget(hr *appv1.HelmRelease) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	prefix := helmreleaseNsn(hr) + "/"
+
+	for key := range h.failures {
+		if strings.HasPrefix(key, prefix) {
+			delete(h.failures, key)
+		}
+	}
+}
+
+// prune drops the failures older than unhealthySourceRetryInterval, they no longer change the order of the
+// sources, so the failures of the sources removed from the HelmReleases are not kept forever
+func (h *sourceHealth) prune(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for key, failure := range h.failures {
+		if now.Sub(failure) >= unhealthySourceRetryInterval {
+			delete(h.failures, key)
+		}
+	}
+}
+
+// healthy returns false if the source failed less than unhealthySourceRetryInterval ago
+func (h *sourceHealth) healthy(key string, now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	failure, ok := h.failures[key]
+
+	return !ok || now.Sub(failure) >= unhealthySourceRetryInterval
+}
+
+// orderSources returns the candidate sources of the HelmRelease in the order they must be tried
+func (h *sourceHealth) orderSources(hr *appv1.HelmRelease, now time.Time) []appv1.AltSource {
+	candidates := hr.Repo.CandidateSources()
+
+	h.prune(now)
+
+	if hr.Repo.GetFailoverPolicy() != appv1.FailoverPolicyPreferred {
+		return verifiedFailover(candidates, candidates)
+	}
+
+	healthy := []appv1.AltSource{}
+	unhealthy := []appv1.AltSource{}
+
+	for _, source := range candidates {
+		if h.healthy(sourceHealthKey(hr, source), now) {
+			healthy = append(healthy, source)
+		} else {
+			unhealthy = append(unhealthy, source)
+		}
+	}
+
+	return verifiedFailover(candidates, append(healthy, unhealthy...))
+}
+
+// verifiedFailover drops the ordered sources that do not verify the chart and are listed after a candidate that
+// verifies it, the failover must not replace a chart that has to be verified by an unverified chart
+func verifiedFailover(candidates, ordered []appv1.AltSource) []appv1.AltSource {
+	allowed := map[string]bool{}
+	verifying := false
+
+	for _, source := range candidates {
+		verifies := utils.SourceVerifiesSignature(source.ToSource())
+		allowed[source.Name] = verifies || !verifying
+		verifying = verifying || verifies
+	}
+
+	sources := []appv1.AltSource{}
+
+	for _, source := range ordered {
+		if allowed[source.Name] {
+			sources = append(sources, source)
+		}
+	}
+
+	return sources
+}
+
+// fromSources calls download with the repo of the HelmRelease set to each candidate source, in the order given by
+// the failover policy, until one serves the chart, and returns that source. The repo is restored on return.
+func (h *sourceHealth) fromSources(s *appv1.HelmRelease, download func() error) (appv1.AltSource, error) {
+	repo := s.Repo
+	defer func() { s.Repo = repo }()
+
+	errs := []string{}
+
+	for _, source := range h.orderSources(s, time.Now()) {
+		key := sourceHealthKey(s, source)
+
+		s.Repo = repo.WithSource(source)
+
+		err := download()
+		if err == nil {
+			h.succeeded(key)
+
+			return source, nil
+		}
+
+		h.failed(key, time.Now())
+
+		// a chart that fails its verification must be refused, not replaced by the chart of a source that
+		// does not verify it
+		if errors.Is(err, utils.ErrChartNotVerified) {
+			return source, fmt.Errorf("failed to verify the chart of source %s: %w", source.Name, err)
+		}
+
+		klog.Warning("Failed to download the chart of HelmRelease ", helmreleaseNsn(s), " from ", source.Name, ": ", err)
+
+		errs = append(errs, source.Name+": "+err.Error())
+	}
+
+	return appv1.AltSource{}, fmt.Errorf("failed to download the chart from all the sources: %s", strings.Join(errs, "; "))
+}
+
+// newHelmOperatorManagerFactoryFromSources downloads the chart from the first candidate source that serves it,
+// in the order given by the failover policy, and records the serving source in the status
+func (r ReconcileHelmRelease) newHelmOperatorManagerFactoryFromSources(
+	s *appv1.HelmRelease) (helmoperator.ManagerFactory, error) {
+	if s.GetDeletionTimestamp() != nil {
+		return r.newHelmOperatorManagerFactory(s)
+	}
+
+	var factory helmoperator.ManagerFactory
+
+	source, err := healthOfSources.fromSources(s, func() (err error) {
+		factory, err = r.newHelmOperatorManagerFactory(s)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Status.ChartSource != nil {
+		s.Status.ChartSource.Name = source.Name
+	}
+
+	return factory, nil
+}
+
+// chartObjectsField indexes the HelmReleases by the ConfigMaps and Secrets that their configmap sources read
+const chartObjectsField = "chartObjects"
+
+func chartObjectKey(kind, name string) string {
+	return kind + "/" + name
+}
+
+// indexChartObjects returns the kind and name of the ConfigMaps and Secrets that the candidate sources of the
+// HelmRelease read their chart from
+func indexChartObjects(obj client.Object) []string {
+	hr, ok := obj.(*appv1.HelmRelease)
+	if !ok {
+		return nil
+	}
+
+	keys := []string{}
+
+	for _, source := range hr.Repo.CandidateSources() {
+		if source.ConfigMap != nil && source.ConfigMap.Name != "" {
+			keys = append(keys, chartObjectKey(utils.ConfigMapSourceKind(source.ConfigMap), source.ConfigMap.Name))
+		}
+	}
+
+	return keys
+}
+
+// chartObjectHelmReleases returns the HelmReleases of the namespace of a ConfigMap or Secret that read their
+// chart from it
+func chartObjectHelmReleases(c client.Client, kind string, obj client.Object) []appv1.HelmRelease {
+	hrList := &appv1.HelmReleaseList{}
+	if err := c.List(context.TODO(), hrList, client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{chartObjectsField: chartObjectKey(kind, obj.GetName())}); err != nil {
+		klog.Error("Failed to list the HelmReleases of namespace ", obj.GetNamespace(), " ", err)
+		return nil
+	}
+
+	return hrList.Items
+}
+
+// chartObjectPredicate drops the events of the ConfigMaps and Secrets that no HelmRelease reads its chart from
+func chartObjectPredicate(c client.Client, kind string) predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return len(chartObjectHelmReleases(c, kind, obj)) > 0
+	})
+}
+
+// chartObjectMapper returns the HelmReleases of the namespace of a ConfigMap or Secret that read their chart from it
+func chartObjectMapper(c client.Client, kind string) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		requests := []reconcile.Request{}
+
+		for _, hr := range chartObjectHelmReleases(c, kind, obj) {
+			klog.V(3).Info("The chart of HelmRelease ", helmreleaseNsn(&hr), " changed in ", kind, " ", obj.GetName())
+
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: hr.Namespace, Name: hr.Name},
+			})
+		}
+
+		return requests
+	}
+}
+
+// chartObjectMetadata returns the metadata only object of the kind, the ConfigMaps and Secrets are watched by
+// their metadata so that their data is not cached, the chart is read from the API server on demand
+func chartObjectMetadata(kind string) *metav1.PartialObjectMetadata {
+	obj := &metav1.PartialObjectMetadata{}
+	obj.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind(kind))
+
+	return obj
+}
@@ -0,0 +1,209 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/operator-framework/operator-lib/handler"
+	"helm.sh/helm/v3/pkg/action"
+	rpb "helm.sh/helm/v3/pkg/release"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/klog"
+)
+
+// the ownership metadata Helm requires on an existing object before importing it into a release.
+// See: helm.sh/helm/v3/pkg/action/validate.go
+const (
+	appManagedByLabel              = "app.kubernetes.io/managed-by"
+	appManagedByHelm               = "Helm"
+	helmReleaseNameAnnotation      = "meta.helm.sh/release-name"
+	helmReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+)
+
+// AdoptRelease migrates the objects of a deployed release that was not created by this operator,
+// for example with the helm CLI, by adding the Helm ownership metadata and the owner reference
+// of the custom resource to them.
+func (m manager) AdoptRelease(ctx context.Context) error {
+	if m.deployedRelease == nil {
+		return fmt.Errorf("release %s/%s is not deployed", m.namespace, m.releaseName)
+	}
+
+	klog.Info("Adopting release ", m.namespace, "/", m.releaseName, " revision ", m.deployedRelease.Version)
+
+	return m.adoptResources(m.deployedRelease.Manifest)
+}
+
+// TakeOwnership renders the chart and adopts the objects that already exist in the cluster
+// so the following install or upgrade can import them into the release instead of failing.
+func (m manager) TakeOwnership(ctx context.Context) error {
+	rel, err := m.RenderRelease(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to render release for adoption: %w", err)
+	}
+
+	return m.adoptResources(rel.Manifest)
+}
+
+// RenderRelease renders the manifests and hooks of the chart with the values of the release
+// without applying them.
+func (m manager) RenderRelease(ctx context.Context) (*rpb.Release, error) {
+	install := action.NewInstall(m.actionConfig)
+	install.ReleaseName = m.releaseName
+	install.Namespace = m.namespace
+	install.DryRun = true
+	install.Replace = true
+	// render as an upgrade so the dry run does not fail on the objects that already exist
+	install.IsUpgrade = true
+
+	return install.Run(m.chart, m.values)
+}
+
+func (m manager) adoptResources(manifest string) error {
+	// the owner reference injecting client sets the owner reference, or the owner annotations
+	// for cluster scoped objects, on the built objects
+	resources, err := m.kubeClient.Build(bytes.NewBufferString(manifest), false)
+	if err != nil {
+		return fmt.Errorf("unable to build kubernetes objects for adoption: %w", err)
+	}
+
+	return resources.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+
+		helper := resource.NewHelper(info.Client, info.Mapping)
+
+		existing, err := helper.Get(info.Namespace, info.Name)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+
+			return fmt.Errorf("failed to get %s %s/%s for adoption: %w", info.Mapping.GroupVersionKind.Kind,
+				info.Namespace, info.Name, err)
+		}
+
+		patch, err := adoptionPatch(existing, info.Object, m.releaseName, m.namespace)
+		if err != nil {
+			return fmt.Errorf("failed to adopt %s %s/%s: %w", info.Mapping.GroupVersionKind.Kind,
+				info.Namespace, info.Name, err)
+		}
+
+		if patch == nil {
+			return nil
+		}
+
+		klog.Info("Adopting ", info.Mapping.GroupVersionKind.Kind, " ", info.Namespace, "/", info.Name,
+			" into release ", m.namespace, "/", m.releaseName)
+
+		_, err = helper.Patch(info.Namespace, info.Name, types.MergePatchType, patch, nil)
+
+		return err
+	})
+}
+
+// adoptionPatch returns the merge patch that adds the Helm ownership metadata and the owner
+// reference (or owner annotations) of desired to existing. It returns nil when existing is already adopted.
+func adoptionPatch(existing, desired interface{}, releaseName, releaseNamespace string) ([]byte, error) {
+	existingMeta, err := meta.Accessor(existing)
+	if err != nil {
+		return nil, err
+	}
+
+	desiredMeta, err := meta.Accessor(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{}
+	if existingMeta.GetLabels()[appManagedByLabel] != appManagedByHelm {
+		labels[appManagedByLabel] = appManagedByHelm
+	}
+
+	annotations := map[string]string{}
+	wanted := map[string]string{
+		helmReleaseNameAnnotation:        releaseName,
+		helmReleaseNamespaceAnnotation:   releaseNamespace,
+		handler.NamespacedNameAnnotation: desiredMeta.GetAnnotations()[handler.NamespacedNameAnnotation],
+		handler.TypeAnnotation:           desiredMeta.GetAnnotations()[handler.TypeAnnotation],
+	}
+
+	for k, v := range wanted {
+		if v != "" && existingMeta.GetAnnotations()[k] != v {
+			annotations[k] = v
+		}
+	}
+
+	ownerRefs, changed, err := mergeOwnerReferences(existingMeta.GetOwnerReferences(), desiredMeta.GetOwnerReferences())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(labels) == 0 && len(annotations) == 0 && !changed {
+		return nil, nil
+	}
+
+	patchMeta := map[string]interface{}{}
+	if len(labels) > 0 {
+		patchMeta["labels"] = labels
+	}
+
+	if len(annotations) > 0 {
+		patchMeta["annotations"] = annotations
+	}
+
+	if changed {
+		patchMeta["ownerReferences"] = ownerRefs
+	}
+
+	return json.Marshal(map[string]interface{}{"metadata": patchMeta})
+}
+
+// mergeOwnerReferences adds the desired owner references to the existing ones. A merge patch replaces
+// the whole list so the existing references are kept in the result.
+func mergeOwnerReferences(existing, desired []metav1.OwnerReference) ([]metav1.OwnerReference, bool, error) {
+	out := append([]metav1.OwnerReference{}, existing...)
+	changed := false
+
+	for _, d := range desired {
+		found := false
+
+		for _, e := range existing {
+			if e.UID == d.UID {
+				found = true
+				break
+			}
+
+			if d.Controller != nil && *d.Controller && e.Controller != nil && *e.Controller {
+				return nil, false, fmt.Errorf("already controlled by %s %s", e.Kind, e.Name)
+			}
+		}
+
+		if !found {
+			out = append(out, d)
+			changed = true
+		}
+	}
+
+	return out, changed, nil
+}
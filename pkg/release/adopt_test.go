@@ -0,0 +1,102 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/chart"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestAdoptionPatch(t *testing.T) {
+	isController := true
+	ownerRef := metav1.OwnerReference{
+		APIVersion: "apps.open-cluster-management.io/v1",
+		Kind:       "HelmRelease",
+		Name:       "test",
+		UID:        types.UID("hr-uid"),
+		Controller: &isController,
+	}
+
+	desired := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name: "test", Namespace: "ns", OwnerReferences: []metav1.OwnerReference{ownerRef},
+	}}
+
+	existing := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "ns"}}
+
+	patch, err := adoptionPatch(existing, desired, "test", "ns")
+	assert.NoError(t, err)
+
+	var out map[string]map[string]interface{}
+	assert.NoError(t, json.Unmarshal(patch, &out))
+	assert.Equal(t, map[string]interface{}{appManagedByLabel: appManagedByHelm}, out["metadata"]["labels"])
+	assert.Equal(t, map[string]interface{}{
+		helmReleaseNameAnnotation:      "test",
+		helmReleaseNamespaceAnnotation: "ns",
+	}, out["metadata"]["annotations"])
+	assert.Len(t, out["metadata"]["ownerReferences"], 1)
+
+	adopted := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:            "test",
+		Namespace:       "ns",
+		Labels:          map[string]string{appManagedByLabel: appManagedByHelm},
+		Annotations:     map[string]string{helmReleaseNameAnnotation: "test", helmReleaseNamespaceAnnotation: "ns"},
+		OwnerReferences: []metav1.OwnerReference{ownerRef},
+	}}
+
+	patch, err = adoptionPatch(adopted, desired, "test", "ns")
+	assert.NoError(t, err)
+	assert.Nil(t, patch, "an adopted object does not need a patch")
+
+	otherRef := ownerRef
+	otherRef.UID = types.UID("other-uid")
+	otherRef.Name = "other"
+	controlled := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name: "test", Namespace: "ns", OwnerReferences: []metav1.OwnerReference{otherRef},
+	}}
+
+	_, err = adoptionPatch(controlled, desired, "test", "ns")
+	assert.Error(t, err, "an object controlled by another owner can not be adopted")
+}
+
+func TestGetReleaseNameAdopt(t *testing.T) {
+	storageBackend := storage.Init(driver.NewMemory())
+
+	err := storageBackend.Create(&rpb.Release{
+		Name:    "test",
+		Version: 1,
+		Chart:   &chart.Chart{Metadata: &chart.Metadata{Name: "cli-chart"}},
+		Info:    &rpb.Info{Status: rpb.StatusDeployed},
+	})
+	assert.NoError(t, err)
+
+	cr := newTestUnstructured(nil)
+	cr.Object["install"] = map[string]interface{}{"adoptRelease": true}
+
+	name, err := getReleaseName(storageBackend, "cli-chart", cr)
+	assert.NoError(t, err)
+	assert.Equal(t, "test", name, "a release of the same chart is adopted")
+
+	_, err = getReleaseName(storageBackend, "operator-chart", cr)
+	assert.Error(t, err, "a release of another chart is a name collision, even when adopting")
+}
@@ -0,0 +1,168 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/klog"
+)
+
+// dryRunFieldManager is the field manager of the server-side dry-run requests
+const dryRunFieldManager = "helmrelease-dry-run"
+
+// ErrDryRunFailed is returned when the API server rejects a manifest of the upgrade in the server-side dry-run
+var ErrDryRunFailed = errors.New("server-side dry-run of the upgrade failed")
+
+// DryRunUpgrade sends the manifests of the candidate release to the API server as server-side apply
+// requests with dryRun=All. The schema validation and the admission webhooks check them, but nothing
+// is persisted and the release is left untouched.
+func (m manager) DryRunUpgrade(ctx context.Context) error {
+	manifest := m.candidateManifest
+	if manifest == "" {
+		rel, err := m.RenderRelease(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to render release for the dry-run: %w", err)
+		}
+
+		manifest = rel.Manifest
+	}
+
+	manifest, unvalidated, err := dryRunManifest(manifest)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDryRunFailed, err)
+	}
+
+	for _, object := range unvalidated {
+		klog.Info("Skipping the server-side dry-run of ", object, " of release ", m.namespace, "/", m.releaseName,
+			", its CustomResourceDefinition or Namespace is part of the upgrade")
+	}
+
+	if strings.TrimSpace(manifest) == "" {
+		return nil
+	}
+
+	resources, err := m.kubeClient.Build(bytes.NewBufferString(manifest), true)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDryRunFailed, err)
+	}
+
+	failures := []string{}
+
+	err = resources.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := dryRunApply(info); err != nil {
+			failures = append(failures, fmt.Sprintf("%s %s/%s: %v", info.Mapping.GroupVersionKind.Kind,
+				info.Namespace, info.Name, err))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%w: %s", ErrDryRunFailed, strings.Join(failures, "; "))
+	}
+
+	klog.Info("Server-side dry-run of the upgrade of release ", m.namespace, "/", m.releaseName, " succeeded")
+
+	return nil
+}
+
+// dryRunApply applies the object with dryRun=All, it takes over the conflicting fields in the dry-run only
+func dryRunApply(info *resource.Info) error {
+	data, err := json.Marshal(info.Object)
+	if err != nil {
+		return err
+	}
+
+	force := true
+
+	_, err = resource.NewHelper(info.Client, info.Mapping).
+		DryRun(true).
+		WithFieldManager(dryRunFieldManager).
+		Patch(info.Namespace, info.Name, types.ApplyPatchType, data, &metav1.PatchOptions{Force: &force})
+
+	return err
+}
+
+// dryRunManifest returns the manifest without the custom resources of the CustomResourceDefinitions and the
+// objects of the Namespaces of the same manifest, and these objects. The dry-run does not persist the
+// CustomResourceDefinitions and the Namespaces, so their objects can not be validated before the upgrade.
+func dryRunManifest(manifest string) (string, []string, error) {
+	objects := []*unstructured.Unstructured{}
+	crds := map[schema.GroupKind]bool{}
+	namespaces := map[string]bool{}
+
+	for _, content := range releaseutil.SplitManifests(manifest) {
+		obj := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(content), &obj.Object); err != nil {
+			return "", nil, err
+		}
+
+		if len(obj.Object) == 0 {
+			continue
+		}
+
+		objects = append(objects, obj)
+
+		if obj.GroupVersionKind().GroupKind() == (schema.GroupKind{Group: "apiextensions.k8s.io",
+			Kind: "CustomResourceDefinition"}) {
+			group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+			kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+			crds[schema.GroupKind{Group: group, Kind: kind}] = true
+		}
+
+		if obj.GroupVersionKind().GroupKind() == (schema.GroupKind{Kind: "Namespace"}) {
+			namespaces[obj.GetName()] = true
+		}
+	}
+
+	validated := []string{}
+	unvalidated := []string{}
+
+	for _, obj := range objects {
+		if crds[obj.GroupVersionKind().GroupKind()] || namespaces[obj.GetNamespace()] {
+			unvalidated = append(unvalidated, obj.GetKind()+" "+obj.GetNamespace()+"/"+obj.GetName())
+			continue
+		}
+
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return "", nil, err
+		}
+
+		validated = append(validated, string(data))
+	}
+
+	return strings.Join(validated, "---\n"), unvalidated, nil
+}
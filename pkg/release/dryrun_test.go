@@ -0,0 +1,159 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest/fake"
+)
+
+func TestDryRunApply(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetName("test")
+	obj.SetNamespace("default")
+
+	var request *http.Request
+
+	var body []byte
+
+	for _, status := range []int{http.StatusOK, http.StatusUnprocessableEntity} {
+		client := &fake.RESTClient{
+			NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+			Client: fake.CreateHTTPClient(func(r *http.Request) (*http.Response, error) {
+				request = r
+				body, _ = ioutil.ReadAll(r.Body)
+
+				return &http.Response{
+					StatusCode: status,
+					Header:     http.Header{"Content-Type": []string{"application/json"}},
+					Body:       ioutil.NopCloser(bytes.NewReader(body)),
+				}, nil
+			}),
+		}
+
+		err := dryRunApply(&resource.Info{
+			Client: client,
+			Mapping: &meta.RESTMapping{
+				Resource:         schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+				GroupVersionKind: obj.GroupVersionKind(),
+				Scope:            meta.RESTScopeNamespace,
+			},
+			Namespace: "default",
+			Name:      "test",
+			Object:    obj,
+		})
+
+		assert.Equal(t, http.MethodPatch, request.Method)
+		assert.Equal(t, "/namespaces/default/configmaps/test", request.URL.Path)
+		assert.Equal(t, "All", request.URL.Query().Get("dryRun"))
+		assert.Equal(t, "true", request.URL.Query().Get("force"))
+		assert.Equal(t, dryRunFieldManager, request.URL.Query().Get("fieldManager"))
+		assert.Equal(t, "application/apply-patch+yaml", request.Header.Get("Content-Type"))
+		assert.JSONEq(t, `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"test","namespace":"default"}}`,
+			string(body))
+
+		if status == http.StatusOK {
+			assert.NoError(t, err)
+		} else {
+			assert.Error(t, err)
+		}
+	}
+}
+
+func TestDryRunManifest(t *testing.T) {
+	manifest := `---
+# Source: chart/templates/crd.yaml
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+---
+# Source: chart/templates/widget.yaml
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: first
+  namespace: default
+---
+# Source: chart/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+  namespace: default
+`
+
+	validated, unvalidated, err := dryRunManifest(manifest)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Widget default/first"}, unvalidated)
+	assert.Contains(t, validated, "kind: CustomResourceDefinition")
+	assert.Contains(t, validated, "kind: ConfigMap")
+	assert.NotContains(t, validated, "name: first")
+
+	// the objects of the Namespaces of the same manifest are not validated
+	validated, unvalidated, err = dryRunManifest(`---
+# Source: chart/templates/namespace.yaml
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: widgets
+---
+# Source: chart/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+  namespace: widgets
+---
+# Source: chart/templates/secret.yaml
+apiVersion: v1
+kind: Secret
+metadata:
+  name: test
+  namespace: default
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ConfigMap widgets/test"}, unvalidated)
+	assert.Contains(t, validated, "kind: Namespace")
+	assert.Contains(t, validated, "kind: Secret")
+	assert.NotContains(t, validated, "kind: ConfigMap")
+
+	// the custom resources of the CustomResourceDefinitions that are already in the cluster are validated
+	validated, unvalidated, err = dryRunManifest(`apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: first
+`)
+	assert.NoError(t, err)
+	assert.Empty(t, unvalidated)
+	assert.Contains(t, validated, "name: first")
+}
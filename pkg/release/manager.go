@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"k8s.io/klog"
 
@@ -30,6 +31,7 @@ import (
 	rpb "helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/storage"
 	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
 )
@@ -45,6 +47,12 @@ type Manager interface {
 	UpgradeRelease(context.Context, ...UpgradeOption) (*rpb.Release, *rpb.Release, error)
 	UninstallRelease(context.Context, ...UninstallOption) (*rpb.Release, error)
 	RollbackRelease(context.Context) error
+	AdoptRelease(context.Context) error
+	OrphanRelease(context.Context, bool) error
+	TakeOwnership(context.Context) error
+	RenderRelease(context.Context) (*rpb.Release, error)
+	DryRunUpgrade(context.Context) error
+	ValidateValues(bool) error
 	GetDeployedRelease() (*rpb.Release, error)
 	GetActionConfig() *action.Configuration
 }
@@ -53,6 +61,7 @@ type manager struct {
 	actionConfig   *action.Configuration
 	storageBackend *storage.Storage
 	kubeClient     kube.Interface
+	owner          *unstructured.Unstructured
 
 	releaseName string
 	namespace   string
@@ -63,6 +72,7 @@ type manager struct {
 	isInstalled       bool
 	isUpgradeRequired bool
 	deployedRelease   *rpb.Release
+	candidateManifest string
 	chart             *cpb.Chart
 }
 
@@ -125,6 +135,7 @@ func (m *manager) Sync(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to get candidate release: %w", err)
 	}
+	m.candidateManifest = candidateRelease.Manifest
 	if deployedRelease.Manifest != candidateRelease.Manifest {
 		m.isUpgradeRequired = true
 	}
@@ -169,6 +180,14 @@ func (m manager) InstallRelease(ctx context.Context, opts ...InstallOption) (*rp
 	return install.Run(m.chart, m.values)
 }
 
+// ReplaceRelease re-uses the name of a deleted or failed release on install.
+func ReplaceRelease(replace bool) InstallOption {
+	return func(i *action.Install) error {
+		i.Replace = replace
+		return nil
+	}
+}
+
 func ForceUpgrade(force bool) UpgradeOption {
 	return func(u *action.Upgrade) error {
 		u.Force = force
@@ -176,6 +195,30 @@ func ForceUpgrade(force bool) UpgradeOption {
 	}
 }
 
+// UninstallTimeout sets the time to wait for the uninstall hooks and the deletion of the resources.
+func UninstallTimeout(timeout time.Duration) UninstallOption {
+	return func(u *action.Uninstall) error {
+		u.Timeout = timeout
+		return nil
+	}
+}
+
+// UninstallWait waits for the release resources to be deleted.
+func UninstallWait(wait bool) UninstallOption {
+	return func(u *action.Uninstall) error {
+		u.Wait = wait
+		return nil
+	}
+}
+
+// KeepHistory keeps the release records marked as uninstalled.
+func KeepHistory(keep bool) UninstallOption {
+	return func(u *action.Uninstall) error {
+		u.KeepHistory = keep
+		return nil
+	}
+}
+
 // UpgradeRelease performs a Helm release upgrade.
 func (m manager) UpgradeRelease(ctx context.Context, opts ...UpgradeOption) (*rpb.Release, *rpb.Release, error) {
 	upgrade := action.NewUpgrade(m.actionConfig)
@@ -202,7 +245,16 @@ func (m manager) UninstallRelease(ctx context.Context, opts ...UninstallOption)
 		}
 	}
 
+	if uninstall.KeepHistory {
+		// helm refuses to uninstall again the release record kept by a previous uninstall
+		last, err := m.storageBackend.Last(m.releaseName)
+		if err == nil && last.Info != nil && last.Info.Status == rpb.StatusUninstalled {
+			return nil, driver.ErrReleaseNotFound
+		}
+	}
+
 	uninstallResponse, err := uninstall.Run(m.releaseName)
+
 	if uninstallResponse == nil {
 		return nil, err
 	}
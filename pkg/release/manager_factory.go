@@ -91,9 +91,16 @@ func (f managerFactory) NewManager(cr *unstructured.Unstructured, overrideValues
 		}
 	}
 
-	crValues, ok := cr.Object["spec"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("failed to get spec: expected map[string]interface{}")
+	// a HelmRelease without spec is installed with the default chart values
+	crValues := map[string]interface{}{}
+
+	if spec, found := cr.Object["spec"]; found && spec != nil {
+		var ok bool
+
+		crValues, ok = spec.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("failed to get spec: expected map[string]interface{}")
+		}
 	}
 
 	expOverrides, err := parseOverrides(overrideValues)
@@ -113,6 +120,7 @@ func (f managerFactory) NewManager(cr *unstructured.Unstructured, overrideValues
 		actionConfig:   actionConfig,
 		storageBackend: storageBackend,
 		kubeClient:     ownerRefClient,
+		owner:          cr,
 
 		releaseName: releaseName,
 		namespace:   cr.GetNamespace(),
@@ -132,7 +140,8 @@ func (f managerFactory) NewManager(cr *unstructured.Unstructured, overrideValues
 // If a release is found but it was created by another chart, that means we
 // have a release name collision, so return an error. This case is possible
 // because Kubernetes allows instances of different types to have the same name
-// in the same namespace.
+// in the same namespace. A release adopted from the helm CLI must therefore
+// have been created by the same chart.
 //
 // TODO(jlanford): As noted above, using the CR name as the release name raises
 //   the possibility of collision. We should move this logic to a validating
@@ -17,6 +17,15 @@
 package release
 
 import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/action"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -57,3 +66,23 @@ func newTestDeployment(containers []v1.Container) *appsv1.Deployment {
 		},
 	}
 }
+
+func TestUninstallReleaseKeptHistory(t *testing.T) {
+	storageBackend := storage.Init(driver.NewMemory())
+
+	err := storageBackend.Create(&rpb.Release{
+		Name:    "test",
+		Version: 1,
+		Info:    &rpb.Info{Status: rpb.StatusUninstalled},
+	})
+	assert.NoError(t, err)
+
+	m := manager{
+		actionConfig:   &action.Configuration{Releases: storageBackend},
+		storageBackend: storageBackend,
+		releaseName:    "test",
+	}
+
+	_, err = m.UninstallRelease(context.TODO(), KeepHistory(true))
+	assert.True(t, errors.Is(err, driver.ErrReleaseNotFound), "the kept record is already uninstalled")
+}
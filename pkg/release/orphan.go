@@ -0,0 +1,148 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/operator-framework/operator-lib/handler"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/klog"
+)
+
+// OrphanRelease detaches the objects of the deployed release from the custom resource by removing
+// its owner references and owner annotations, so they keep running after the custom resource is
+// deleted. The Helm release records are deleted as well unless keepReleaseRecords is true, in which
+// case the release can later be adopted by another HelmRelease or the helm CLI.
+func (m manager) OrphanRelease(ctx context.Context, keepReleaseRecords bool) error {
+	deployedRelease, err := m.GetDeployedRelease()
+	if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+		return fmt.Errorf("failed to get deployed release: %w", err)
+	}
+
+	if deployedRelease != nil {
+		klog.Info("Orphaning the resources of release ", m.namespace, "/", m.releaseName,
+			" revision ", deployedRelease.Version)
+
+		if err := m.orphanResources(deployedRelease.Manifest); err != nil {
+			return err
+		}
+	}
+
+	if keepReleaseRecords {
+		return nil
+	}
+
+	releases, err := m.storageBackend.History(m.releaseName)
+	if err != nil && !notFoundErr(err) {
+		return fmt.Errorf("failed to retrieve release history: %w", err)
+	}
+
+	for _, rel := range releases {
+		klog.Info("Helm storage backend deleting: ", rel.Name, "/", rel.Version)
+
+		if _, err := m.storageBackend.Delete(rel.Name, rel.Version); err != nil && !notFoundErr(err) {
+			return fmt.Errorf("failed to delete release record: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (m manager) orphanResources(manifest string) error {
+	if m.owner == nil {
+		return fmt.Errorf("release %s/%s has no owner to orphan the resources from", m.namespace, m.releaseName)
+	}
+
+	resources, err := m.kubeClient.Build(bytes.NewBufferString(manifest), false)
+	if err != nil {
+		return fmt.Errorf("unable to build kubernetes objects to orphan: %w", err)
+	}
+
+	ownerNsn := fmt.Sprintf("%s/%s", m.owner.GetNamespace(), m.owner.GetName())
+
+	return resources.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+
+		helper := resource.NewHelper(info.Client, info.Mapping)
+
+		existing, err := helper.Get(info.Namespace, info.Name)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+
+			return fmt.Errorf("failed to get %s %s/%s to orphan: %w", info.Mapping.GroupVersionKind.Kind,
+				info.Namespace, info.Name, err)
+		}
+
+		patch, err := orphanPatch(existing, m.owner.GetUID(), ownerNsn)
+		if err != nil || patch == nil {
+			return err
+		}
+
+		klog.Info("Orphaning ", info.Mapping.GroupVersionKind.Kind, " ", info.Namespace, "/", info.Name)
+
+		_, err = helper.Patch(info.Namespace, info.Name, types.MergePatchType, patch, nil)
+
+		return err
+	})
+}
+
+// orphanPatch returns the merge patch that removes the owner reference with ownerUID and the owner
+// annotations pointing at ownerNsn from existing. It returns nil when there is nothing to remove.
+func orphanPatch(existing interface{}, ownerUID types.UID, ownerNsn string) ([]byte, error) {
+	existingMeta, err := meta.Accessor(existing)
+	if err != nil {
+		return nil, err
+	}
+
+	patchMeta := map[string]interface{}{}
+
+	ownerRefs := []metav1.OwnerReference{}
+	for _, ref := range existingMeta.GetOwnerReferences() {
+		if ref.UID != ownerUID {
+			ownerRefs = append(ownerRefs, ref)
+		}
+	}
+
+	if len(ownerRefs) != len(existingMeta.GetOwnerReferences()) {
+		patchMeta["ownerReferences"] = ownerRefs
+	}
+
+	if existingMeta.GetAnnotations()[handler.NamespacedNameAnnotation] == ownerNsn {
+		patchMeta["annotations"] = map[string]interface{}{
+			handler.NamespacedNameAnnotation: nil,
+			handler.TypeAnnotation:           nil,
+		}
+	}
+
+	if len(patchMeta) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(map[string]interface{}{"metadata": patchMeta})
+}
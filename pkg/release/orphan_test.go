@@ -0,0 +1,114 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/kube/fake"
+	rpb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestOrphanPatch(t *testing.T) {
+	ownerRef := metav1.OwnerReference{Kind: "HelmRelease", Name: "test", UID: types.UID("hr-uid")}
+	otherRef := metav1.OwnerReference{Kind: "ConfigMap", Name: "other", UID: types.UID("other-uid")}
+
+	owned := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name: "test", Namespace: "ns", OwnerReferences: []metav1.OwnerReference{otherRef, ownerRef},
+	}}
+
+	patch, err := orphanPatch(owned, types.UID("hr-uid"), "ns/test")
+	assert.NoError(t, err)
+
+	var out struct {
+		Metadata struct {
+			OwnerReferences []metav1.OwnerReference `json:"ownerReferences"`
+		} `json:"metadata"`
+	}
+	assert.NoError(t, json.Unmarshal(patch, &out))
+	assert.Equal(t, []metav1.OwnerReference{otherRef}, out.Metadata.OwnerReferences)
+
+	notOwned := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name: "test", Namespace: "ns", OwnerReferences: []metav1.OwnerReference{otherRef},
+	}}
+
+	patch, err = orphanPatch(notOwned, types.UID("hr-uid"), "ns/test")
+	assert.NoError(t, err)
+	assert.Nil(t, patch)
+}
+
+func newOrphanTestManager(t *testing.T) manager {
+	storageBackend := storage.Init(driver.NewMemory())
+
+	for _, rel := range []*rpb.Release{
+		{Name: "test", Version: 1, Info: &rpb.Info{Status: rpb.StatusSuperseded}},
+		{Name: "test", Version: 2, Info: &rpb.Info{Status: rpb.StatusDeployed}},
+	} {
+		assert.NoError(t, storageBackend.Create(rel))
+	}
+
+	return manager{
+		storageBackend: storageBackend,
+		kubeClient:     &fake.PrintingKubeClient{},
+		owner:          newTestUnstructured(nil),
+		releaseName:    "test",
+		namespace:      "ns",
+	}
+}
+
+func TestOrphanRelease(t *testing.T) {
+	m := newOrphanTestManager(t)
+
+	assert.NoError(t, m.OrphanRelease(context.TODO(), false))
+
+	history, err := m.storageBackend.History("test")
+	assert.True(t, err != nil || len(history) == 0, "the release records are deleted")
+
+	// orphaning again, once the records are gone, succeeds
+	assert.NoError(t, m.OrphanRelease(context.TODO(), false))
+}
+
+func TestOrphanReleaseKeepReleaseRecord(t *testing.T) {
+	m := newOrphanTestManager(t)
+
+	assert.NoError(t, m.OrphanRelease(context.TODO(), true))
+
+	history, err := m.storageBackend.History("test")
+	assert.NoError(t, err)
+	assert.Len(t, history, 2, "the release records are kept for a later adoption")
+
+	deployed, err := m.GetDeployedRelease()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, deployed.Version)
+}
+
+func TestOrphanReleaseWithoutOwner(t *testing.T) {
+	m := newOrphanTestManager(t)
+	m.owner = nil
+
+	assert.Error(t, m.OrphanRelease(context.TODO(), false))
+
+	history, err := m.storageBackend.History("test")
+	assert.NoError(t, err)
+	assert.Len(t, history, 2, "the release records are kept when the resources can not be orphaned")
+}
@@ -0,0 +1,263 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+	cpb "helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// ValuesInvalidError lists the errors of the values of the release, each prefixed by its JSON path
+type ValuesInvalidError struct {
+	Errors []string
+}
+
+func (e *ValuesInvalidError) Error() string {
+	return fmt.Sprintf("%d invalid values: %s", len(e.Errors), strings.Join(e.Errors, "; "))
+}
+
+// ValidateValues validates the values of the release against the chart, see ValidateChartValues
+func (m manager) ValidateValues(rejectUnknown bool) error {
+	if m.chart == nil {
+		return nil
+	}
+
+	return ValidateChartValues(m.chart, m.values, rejectUnknown)
+}
+
+// ValidateChartValues validates the values merged with the chart defaults against the values.schema.json of the
+// chart and of its subcharts. With rejectUnknown, the values that the chart defines neither in its values.yaml
+// nor in its values.schema.json are errors too.
+func ValidateChartValues(chrt *cpb.Chart, values map[string]interface{}, rejectUnknown bool) error {
+	// like the Helm install, the aliased subcharts take their values under their alias and the subcharts
+	// disabled by their condition or tags are not validated. The dependencies are processed on a copy as
+	// the processing changes the chart.
+	processed := copyChart(chrt)
+	if err := chartutil.ProcessDependencies(processed, values); err != nil {
+		return fmt.Errorf("failed to process the dependencies of the chart: %w", err)
+	}
+
+	merged, err := chartutil.CoalesceValues(processed, values)
+	if err != nil {
+		return fmt.Errorf("failed to merge the values with the chart defaults: %w", err)
+	}
+
+	errs := validateChartValues(processed, merged, "$")
+
+	if rejectUnknown {
+		errs = append(errs, unknownChartValues(chrt, values, "$")...)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	sort.Strings(errs)
+
+	return &ValuesInvalidError{Errors: errs}
+}
+
+// copyChart returns a copy of the chart and of its subcharts that can be changed without changing the chart
+func copyChart(chrt *cpb.Chart) *cpb.Chart {
+	out := *chrt
+
+	if chrt.Metadata != nil {
+		metadata := *chrt.Metadata
+		metadata.Dependencies = []*cpb.Dependency{}
+
+		for _, dep := range chrt.Metadata.Dependencies {
+			d := *dep
+			metadata.Dependencies = append(metadata.Dependencies, &d)
+		}
+
+		out.Metadata = &metadata
+	}
+
+	subcharts := []*cpb.Chart{}
+	for _, sub := range chrt.Dependencies() {
+		subcharts = append(subcharts, copyChart(sub))
+	}
+
+	out.SetDependencies(subcharts...)
+
+	return &out
+}
+
+// validateChartValues validates the values against the schema of the chart and the values of each subchart
+// against the schema of the subchart, like the Helm install does
+func validateChartValues(chrt *cpb.Chart, values map[string]interface{}, path string) []string {
+	errs := []string{}
+
+	if len(chrt.Schema) > 0 {
+		errs = append(errs, validateSchema(chrt.Schema, values, path)...)
+	}
+
+	for _, sub := range chrt.Dependencies() {
+		subValues, _ := values[sub.Name()].(map[string]interface{})
+		if subValues == nil {
+			subValues = map[string]interface{}{}
+		}
+
+		errs = append(errs, validateChartValues(sub, subValues, path+"."+sub.Name())...)
+	}
+
+	return errs
+}
+
+// validateSchema returns the errors of the values against the JSON schema
+func validateSchema(schema []byte, values map[string]interface{}, path string) []string {
+	valuesJSON, err := json.Marshal(values)
+	if err != nil {
+		return []string{fmt.Sprintf("%s: %v", path, err)}
+	}
+
+	result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(schema), gojsonschema.NewBytesLoader(valuesJSON))
+	if err != nil {
+		return []string{fmt.Sprintf("%s: invalid values.schema.json: %v", path, err)}
+	}
+
+	errs := []string{}
+
+	for _, e := range result.Errors() {
+		errs = append(errs, fmt.Sprintf("%s: %s", jsonPath(path, e.Context().String(".")), e.Description()))
+	}
+
+	return errs
+}
+
+// jsonPath returns the JSON path of the field of the schema error below the path, with the array indexes
+// in brackets
+func jsonPath(path, field string) string {
+	for _, segment := range strings.Split(field, ".") {
+		if segment == gojsonschema.STRING_CONTEXT_ROOT {
+			continue
+		}
+
+		if _, err := strconv.Atoi(segment); err == nil {
+			path += "[" + segment + "]"
+		} else {
+			path += "." + segment
+		}
+	}
+
+	return path
+}
+
+// unknownChartValues returns the values that the chart does not define, the values of the subcharts are
+// checked against the subcharts and the global values are not checked
+func unknownChartValues(chrt *cpb.Chart, values map[string]interface{}, path string) []string {
+	byName := map[string]*cpb.Chart{}
+	for _, sub := range chrt.Dependencies() {
+		byName[sub.Name()] = sub
+	}
+
+	// the subcharts take their values under their alias, the subcharts that are not dependencies of the
+	// Chart.yaml under their name
+	subcharts := map[string]*cpb.Chart{}
+	dependencies := map[string]bool{}
+
+	if chrt.Metadata != nil {
+		for _, dep := range chrt.Metadata.Dependencies {
+			dependencies[dep.Name] = true
+
+			key := dep.Name
+			if dep.Alias != "" {
+				key = dep.Alias
+			}
+
+			if sub, ok := byName[dep.Name]; ok {
+				subcharts[key] = sub
+			}
+		}
+	}
+
+	for name, sub := range byName {
+		if !dependencies[name] {
+			subcharts[name] = sub
+		}
+	}
+
+	schema := map[string]interface{}{}
+	if len(chrt.Schema) > 0 {
+		_ = json.Unmarshal(chrt.Schema, &schema)
+	}
+
+	chartValues := map[string]interface{}{}
+	errs := []string{}
+
+	for key, value := range values {
+		if key == "global" {
+			continue
+		}
+
+		sub, ok := subcharts[key]
+		if !ok {
+			chartValues[key] = value
+			continue
+		}
+
+		if subValues, ok := value.(map[string]interface{}); ok {
+			errs = append(errs, unknownChartValues(sub, subValues, path+"."+key)...)
+		}
+	}
+
+	return append(errs, unknownValues(chrt.Values, schema, chartValues, path)...)
+}
+
+// unknownValues returns the paths of the values that are neither in the defaults nor in the properties of
+// the schema. The values below a default or a property without keys, like an empty map or a map without
+// properties, are free-form.
+func unknownValues(defaults, schema, values map[string]interface{}, path string) []string {
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	if len(defaults) == 0 && len(properties) == 0 {
+		return nil
+	}
+
+	if additional, ok := schema["additionalProperties"]; ok && additional != false {
+		return nil
+	}
+
+	errs := []string{}
+
+	for key, value := range values {
+		keyPath := path + "." + key
+
+		defaultValue, inDefaults := defaults[key]
+		property, inSchema := properties[key].(map[string]interface{})
+
+		if !inDefaults && !inSchema {
+			errs = append(errs, keyPath+": the chart does not define this value")
+			continue
+		}
+
+		v, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		d, _ := defaultValue.(map[string]interface{})
+		errs = append(errs, unknownValues(d, property, v, keyPath)...)
+	}
+
+	return errs
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 The Kubernetes Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func TestValidateValues(t *testing.T) {
+	newChart := func() *chart.Chart {
+		sub := &chart.Chart{
+			Metadata: &chart.Metadata{Name: "redis", Version: "0.1.0"},
+			Values:   map[string]interface{}{"port": float64(6379)},
+			Schema: []byte(`{"type":"object","properties":{"port":{"type":"integer","minimum":1}},` +
+				`"required":["port"]}`),
+		}
+
+		chrt := &chart.Chart{
+			Metadata: &chart.Metadata{
+				Name:         "guestbook",
+				Version:      "0.1.0",
+				Dependencies: []*chart.Dependency{
+					{Name: "redis", Version: "0.1.x", Alias: "cache", Condition: "cache.enabled"},
+				},
+			},
+			Values: map[string]interface{}{
+				"image":          map[string]interface{}{"repository": "nginx", "tag": "1.21"},
+				"podAnnotations": map[string]interface{}{},
+				"ports":          []interface{}{float64(80)},
+			},
+			Schema: []byte(`{"type":"object","properties":{` +
+				`"image":{"type":"object","properties":{"tag":{"type":"string"}}},` +
+				`"ports":{"type":"array","items":{"type":"integer"}},` +
+				`"replicas":{"type":"integer"}}}`),
+		}
+		chrt.AddDependency(sub)
+
+		return chrt
+	}
+
+	tests := []struct {
+		name          string
+		values        map[string]interface{}
+		rejectUnknown bool
+		errs          []string
+	}{
+		{name: "defaults"},
+		{
+			name: "valid",
+			values: map[string]interface{}{
+				"replicas":       float64(2),
+				"podAnnotations": map[string]interface{}{"team": "a"},
+				"cache":          map[string]interface{}{"port": float64(6380)},
+				"global":         map[string]interface{}{"domain": "example.com"},
+			},
+			rejectUnknown: true,
+		},
+		{
+			name: "schema errors",
+			values: map[string]interface{}{
+				"image": map[string]interface{}{"tag": float64(1)},
+				"ports": []interface{}{float64(80), "https"},
+				"cache": map[string]interface{}{"port": float64(0)},
+			},
+			errs: []string{
+				"$.cache.port: Must be greater than or equal to 1",
+				"$.image.tag: Invalid type. Expected: string, given: integer",
+				"$.ports[1]: Invalid type. Expected: integer, given: string",
+			},
+		},
+		{
+			name: "values of the chart name of an aliased subchart not validated",
+			values: map[string]interface{}{
+				"redis": map[string]interface{}{"port": float64(0)},
+			},
+		},
+		{
+			name: "disabled subchart not validated",
+			values: map[string]interface{}{
+				"cache": map[string]interface{}{"enabled": false, "port": float64(0)},
+			},
+		},
+		{
+			name: "unknown values ignored",
+			values: map[string]interface{}{
+				"unused": "unused",
+			},
+		},
+		{
+			name: "unknown values rejected",
+			values: map[string]interface{}{
+				"unused": "unused",
+				"image":  map[string]interface{}{"pullPolicy": "Always"},
+				"cache":  map[string]interface{}{"password": "secret"},
+				"redis":  map[string]interface{}{"port": float64(6380)},
+			},
+			rejectUnknown: true,
+			errs: []string{
+				"$.cache.password: the chart does not define this value",
+				"$.image.pullPolicy: the chart does not define this value",
+				"$.redis: the chart does not define this value",
+				"$.unused: the chart does not define this value",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		values := tt.values
+		if values == nil {
+			values = map[string]interface{}{}
+		}
+
+		chrt := newChart()
+
+		err := manager{chart: chrt, values: values}.ValidateValues(tt.rejectUnknown)
+
+		// the dependencies are processed on a copy of the chart
+		assert.Equal(t, "redis", chrt.Dependencies()[0].Name(), tt.name)
+		assert.Equal(t, "redis", chrt.Metadata.Dependencies[0].Name, tt.name)
+
+		if len(tt.errs) == 0 {
+			assert.NoError(t, err, tt.name)
+			continue
+		}
+
+		var invalid *ValuesInvalidError
+		if assert.True(t, errors.As(err, &invalid), tt.name) {
+			assert.Equal(t, tt.errs, invalid.Errors, tt.name)
+		}
+	}
+}
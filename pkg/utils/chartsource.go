@@ -0,0 +1,236 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// ChartSource fetches charts from one type of source. The implementations register themselves
+// with RegisterChartSource for the source type they handle.
+type ChartSource interface {
+	// Resolve validates the source and returns the locations the chart can be fetched from, in order
+	Resolve(source *appv1.Source) ([]string, error)
+	// Fetch downloads the chart from one of the resolved locations and expands it in req.DestDir
+	Fetch(req *ChartRequest, location string) (*Chart, error)
+	// Describe returns a short description of the source
+	Describe(source *appv1.Source) string
+}
+
+// ChartRequest holds what a ChartSource needs to fetch the chart of a HelmRelease
+type ChartRequest struct {
+	HelmRelease *appv1.HelmRelease
+	Source      *appv1.Source
+	ConfigMap   *corev1.ConfigMap
+	Secret      *corev1.Secret
+	// Client reads the Kubernetes objects the chart is fetched from, it is nil outside of the controller
+	Client client.Client
+	// DestDir is the directory reserved for the chart of the HelmRelease
+	DestDir string
+	// CacheDir is the directory shared by the HelmReleases to cache the sources, nothing is cached when empty
+	CacheDir string
+}
+
+// Chart is a chart fetched by a ChartSource
+type Chart struct {
+	// Dir is the directory of the expanded chart
+	Dir string
+	// Location is the location the chart was fetched from
+	Location string
+	// Revision identifies the fetched content, for example the git commit or the digest of the chart archive
+	Revision string
+	// SignedBy is the identity of the key that signed the chart, it is empty when the chart is not verified
+	SignedBy string
+}
+
+var (
+	chartSourcesMu sync.RWMutex
+	chartSources   = map[appv1.SourceTypeEnum]ChartSource{}
+)
+
+// RegisterChartSource registers the ChartSource of a source type. It replaces the ChartSource
+// previously registered for the same type.
+func RegisterChartSource(sourceType appv1.SourceTypeEnum, source ChartSource) {
+	chartSourcesMu.Lock()
+	defer chartSourcesMu.Unlock()
+
+	chartSources[appv1.SourceTypeEnum(strings.ToLower(string(sourceType)))] = source
+}
+
+// GetChartSource returns the ChartSource registered for a source type
+func GetChartSource(sourceType appv1.SourceTypeEnum) (ChartSource, error) {
+	chartSourcesMu.RLock()
+	defer chartSourcesMu.RUnlock()
+
+	source, ok := chartSources[appv1.SourceTypeEnum(strings.ToLower(string(sourceType)))]
+	if !ok {
+		return nil, fmt.Errorf("sourceType '%s' unsupported", sourceType)
+	}
+
+	return source, nil
+}
+
+// SourceVerifiesSignature returns true if the source refuses the charts whose signature is not verified
+func SourceVerifiesSignature(source *appv1.Source) bool {
+	if source == nil {
+		return false
+	}
+
+	switch appv1.SourceTypeEnum(strings.ToLower(string(source.SourceType))) {
+	case appv1.HelmRepoSourceType:
+		return source.HelmRepo != nil && (source.HelmRepo.Verify || source.HelmRepo.Cosign != nil)
+	case appv1.OCISourceType:
+		return source.OCI != nil && source.OCI.Cosign != nil
+	}
+
+	return false
+}
+
+// ChartSourceStatus returns the status of the source a chart was fetched from
+func ChartSourceStatus(source *appv1.Source, chart *Chart) *appv1.HelmAppChartSource {
+	status := &appv1.HelmAppChartSource{
+		Type:     source.SourceType,
+		Location: chart.Location,
+		Revision: chart.Revision,
+		SignedBy: chart.SignedBy,
+	}
+
+	if chartSource, err := GetChartSource(source.SourceType); err == nil {
+		status.Description = chartSource.Describe(source)
+	}
+
+	return status
+}
+
+//FetchChart downloads the chart of the HelmRelease from its Repo.Source
+func FetchChart(configMap *corev1.ConfigMap,
+	secret *corev1.Secret,
+	chartsDir string,
+	s *appv1.HelmRelease) (*Chart, error) {
+	return FetchChartWithClient(nil, configMap, secret, chartsDir, s)
+}
+
+//FetchChartWithClient downloads the chart of the HelmRelease from its Repo.Source, the client reads the
+//ConfigMaps and Secrets of the configmap sources
+func FetchChartWithClient(c client.Client,
+	configMap *corev1.ConfigMap,
+	secret *corev1.Secret,
+	chartsDir string,
+	s *appv1.HelmRelease) (*Chart, error) {
+	if s.Repo.Source == nil {
+		return nil, fmt.Errorf("repo.source is not defined")
+	}
+
+	destRepo := filepath.Join(chartsDir, s.Name, s.Namespace, s.Repo.ChartName)
+	if _, err := os.Stat(destRepo); os.IsNotExist(err) {
+		err := os.MkdirAll(destRepo, 0750)
+		if err != nil {
+			klog.Error(err, " - Unable to create chartDir: ", destRepo)
+			return nil, err
+		}
+	}
+
+	return fetchChart(&ChartRequest{
+		HelmRelease: s,
+		Source:      s.Repo.Source,
+		ConfigMap:   configMap,
+		Secret:      secret,
+		Client:      c,
+		DestDir:     destRepo,
+		CacheDir:    filepath.Join(chartsDir, ".cache"),
+	})
+}
+
+//DownloadChart downloads the charts
+func DownloadChart(configMap *corev1.ConfigMap,
+	secret *corev1.Secret,
+	chartsDir string,
+	s *appv1.HelmRelease) (chartDir string, err error) {
+	chart, err := FetchChart(configMap, secret, chartsDir, s)
+	if err != nil {
+		return "", err
+	}
+
+	return chart.Dir, nil
+}
+
+// fetchChart fetches the chart with the ChartSource registered for the type of the source
+func fetchChart(req *ChartRequest) (*Chart, error) {
+	chartSource, err := GetChartSource(req.Source.SourceType)
+	if err != nil {
+		return nil, err
+	}
+
+	return fetchChartFrom(chartSource, req)
+}
+
+// fetchChartFrom tries the locations of the source in order and returns the first chart fetched
+func fetchChartFrom(chartSource ChartSource, req *ChartRequest) (*Chart, error) {
+	locations, err := chartSource.Resolve(req.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	var locationsError string
+
+	notVerified := false
+
+	for _, location := range locations {
+		chart, err := chartSource.Fetch(req, location)
+		if err == nil {
+			klog.V(3).Info("Fetched chart from ", chartSource.Describe(req.Source), " location: ", location,
+				" revision: ", chart.Revision)
+
+			return chart, nil
+		}
+
+		notVerified = notVerified || errors.Is(err, ErrChartNotVerified)
+		locationsError += " - url: " + location + " error: " + err.Error()
+	}
+
+	err = fmt.Errorf("failed to download chart from %s.%s", req.Source.SourceType, locationsError)
+	if notVerified {
+		return nil, notVerifiedError{err}
+	}
+
+	return nil, err
+}
+
+// notVerifiedError is the error of the locations of a source when one of them failed the provenance
+// verification, it matches ErrChartNotVerified
+type notVerifiedError struct {
+	error
+}
+
+func (e notVerifiedError) Is(target error) bool {
+	return target == ErrChartNotVerified
+}
+
+func (e notVerifiedError) Unwrap() error {
+	return e.error
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const (
+	// ConfigMapKind is the kind of the configmap sources that read a ConfigMap
+	ConfigMapKind = "ConfigMap"
+	// SecretKind is the kind of the configmap sources that read a Secret
+	SecretKind = "Secret"
+)
+
+func init() {
+	RegisterChartSource(appv1.ConfigMapSourceType, configMapChartSource{})
+}
+
+// configMapChartSource reads the chart archive from a ConfigMap or a Secret of the namespace of the HelmRelease
+type configMapChartSource struct{}
+
+// ConfigMapSourceKind returns the kind of the object of a configmap source
+func ConfigMapSourceKind(source *appv1.ConfigMapSource) string {
+	if source.Kind == "" {
+		return ConfigMapKind
+	}
+
+	return source.Kind
+}
+
+func (configMapChartSource) Resolve(source *appv1.Source) ([]string, error) {
+	if source.ConfigMap == nil {
+		return nil, fmt.Errorf("configmap type but Source.ConfigMap is not defined")
+	}
+
+	if source.ConfigMap.Name == "" {
+		return nil, fmt.Errorf("configmap type, need Source.ConfigMap.Name to be populated")
+	}
+
+	kind := ConfigMapSourceKind(source.ConfigMap)
+	if kind != ConfigMapKind && kind != SecretKind {
+		return nil, fmt.Errorf("configmap type, Source.ConfigMap.Kind must be %s or %s", ConfigMapKind, SecretKind)
+	}
+
+	return []string{kind + "/" + source.ConfigMap.Name}, nil
+}
+
+func (configMapChartSource) Fetch(req *ChartRequest, location string) (*Chart, error) {
+	if req.Client == nil {
+		return nil, fmt.Errorf("no client to read %s", location)
+	}
+
+	data, err := configMapSourceData(req.Client, req.HelmRelease.Namespace, req.Source.ConfigMap)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := joinChartArchive(data, req.Source.ConfigMap.Keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the chart archive of %s: %w", location, err)
+	}
+
+	chartZip := filepath.Join(req.DestDir, req.HelmRelease.Repo.ChartName+".tgz")
+
+	if err := ioutil.WriteFile(chartZip, archive, 0600); err != nil {
+		klog.Error(err, " - Failed to write: ", chartZip)
+		return nil, err
+	}
+
+	objectLocation := req.HelmRelease.Namespace + "/" + location
+
+	chartDir, revision, err := expandChartArchive(req.DestDir, req.HelmRelease.Repo.ChartName, chartZip, objectLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Chart{Dir: chartDir, Location: objectLocation, Revision: revision}, nil
+}
+
+func (configMapChartSource) Describe(source *appv1.Source) string {
+	if source.ConfigMap == nil {
+		return string(source.SourceType)
+	}
+
+	return ConfigMapSourceKind(source.ConfigMap) + "/" + source.ConfigMap.Name
+}
+
+// configMapSourceData returns the binaryData of the ConfigMap or the data of the Secret of the source
+func configMapSourceData(c client.Client, namespace string, source *appv1.ConfigMapSource) (map[string][]byte, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: source.Name}
+
+	if ConfigMapSourceKind(source) == SecretKind {
+		secret := &corev1.Secret{}
+		if err := c.Get(context.TODO(), key, secret); err != nil {
+			return nil, err
+		}
+
+		return secret.Data, nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := c.Get(context.TODO(), key, configMap); err != nil {
+		return nil, err
+	}
+
+	return configMap.BinaryData, nil
+}
+
+// joinChartArchive concatenates the parts of the chart archive in the order of the keys, or in the lexical
+// order of the data keys when no key is given
+func joinChartArchive(data map[string][]byte, keys []string) ([]byte, error) {
+	if len(keys) == 0 {
+		for k := range data {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no chart archive data")
+	}
+
+	archive := []byte{}
+
+	for _, k := range keys {
+		part, ok := data[k]
+		if !ok {
+			return nil, fmt.Errorf("key %s not found", k)
+		}
+
+		archive = append(archive, part...)
+	}
+
+	return archive, nil
+}
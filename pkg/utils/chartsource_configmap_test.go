@@ -0,0 +1,110 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestFetchChartConfigMap(t *testing.T) {
+	chartZip, err := ioutil.ReadFile("../../test/helmrepo/subscription-release-test-1-0.1.0.tgz")
+	assert.NoError(t, err)
+
+	half := len(chartZip) / 2
+
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "chart", Namespace: "default"},
+			BinaryData: map[string][]byte{"part-1": chartZip[:half], "part-2": chartZip[half:]},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "chart", Namespace: "default"},
+			Data:       map[string][]byte{"b": chartZip[:half], "a": chartZip[half:]},
+		},
+	).Build()
+
+	tests := []struct {
+		name    string
+		source  *appv1.ConfigMapSource
+		wantErr bool
+	}{
+		{name: "configmap sorted keys", source: &appv1.ConfigMapSource{Name: "chart"}},
+		{name: "secret keys", source: &appv1.ConfigMapSource{Kind: "Secret", Name: "chart", Keys: []string{"b", "a"}}},
+		{name: "secret sorted keys", source: &appv1.ConfigMapSource{Kind: "Secret", Name: "chart"}, wantErr: true},
+		{name: "missing key", source: &appv1.ConfigMapSource{Name: "chart", Keys: []string{"part-3"}}, wantErr: true},
+		{name: "missing object", source: &appv1.ConfigMapSource{Name: "missing"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("/tmp", "charts")
+			assert.NoError(t, err)
+
+			defer os.RemoveAll(dir)
+
+			hr := &appv1.HelmRelease{
+				ObjectMeta: metav1.ObjectMeta{Name: "subscription-release-test-1-cr", Namespace: "default"},
+				Repo: appv1.HelmReleaseRepo{
+					Source:    &appv1.Source{SourceType: appv1.ConfigMapSourceType, ConfigMap: tt.source},
+					ChartName: "subscription-release-test-1",
+				},
+			}
+
+			chart, err := FetchChartWithClient(c, nil, nil, dir, hr)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			_, err = os.Stat(filepath.Join(chart.Dir, "Chart.yaml"))
+			assert.NoError(t, err)
+
+			digest, err := fileDigest("../../test/helmrepo/subscription-release-test-1-0.1.0.tgz")
+			assert.NoError(t, err)
+			assert.Equal(t, digest, chart.Revision)
+		})
+	}
+
+	// no client outside of the controller
+	dir, err := ioutil.TempDir("/tmp", "charts")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	_, err = FetchChart(nil, nil, dir, &appv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "subscription-release-test-1-cr", Namespace: "default"},
+		Repo: appv1.HelmReleaseRepo{
+			Source: &appv1.Source{SourceType: appv1.ConfigMapSourceType, ConfigMap: &appv1.ConfigMapSource{Name: "chart"}},
+		},
+	})
+	assert.Error(t, err)
+}
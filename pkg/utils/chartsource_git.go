@@ -0,0 +1,121 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"k8s.io/klog"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func init() {
+	RegisterChartSource(appv1.GitHubSourceType, gitChartSource{})
+	RegisterChartSource(appv1.GitSourceType, gitChartSource{})
+}
+
+// gitChartSource fetches the branch of a git repository and uses the chart at ChartPath.
+// It serves both the github and the git source types. With a cache directory, the branch is fetched
+// into a bare repository shared by the HelmReleases and only ChartPath is checked out, otherwise
+// the branch is cloned.
+type gitChartSource struct{}
+
+// gitParams returns the parameters of the github or git source, whichever is populated
+func gitParams(source *appv1.Source) (*appv1.Git, error) {
+	switch {
+	case source.GitHub != nil:
+		params := appv1.Git(*source.GitHub)
+		return &params, nil
+	case source.Git != nil:
+		return source.Git, nil
+	default:
+		return nil, fmt.Errorf("git type, need Repo.Source.Git or Repo.Source.GitHub to be populated.")
+	}
+}
+
+func (gitChartSource) Resolve(source *appv1.Source) ([]string, error) {
+	params, err := gitParams(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return params.Urls, nil
+}
+
+func (gitChartSource) Fetch(req *ChartRequest, location string) (*Chart, error) {
+	params, err := gitParams(req.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	chartDir := filepath.Join(req.DestDir, params.ChartPath)
+	submodules := !params.DisableSubmodules
+
+	if req.CacheDir != "" {
+		commitID, err := fetchFromGitCache(req, location, params)
+		if err == nil {
+			return &Chart{Dir: chartDir, Location: location, Revision: commitID}, nil
+		}
+
+		if !errors.Is(err, errGitSubmodules) {
+			return nil, err
+		}
+
+		klog.V(3).Info("Cloning ", location, " to check out the submodules of ", params.ChartPath)
+	}
+
+	commitID, err := downloadGitRepo(req.ConfigMap, req.Secret, req.DestDir, []string{location}, params.Branch,
+		req.HelmRelease.Repo.InsecureSkipVerify, submodules)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Chart{Dir: chartDir, Location: location, Revision: commitID}, nil
+}
+
+func (gitChartSource) Describe(source *appv1.Source) string {
+	params, err := gitParams(source)
+	if err != nil {
+		return string(source.SourceType)
+	}
+
+	return fmt.Sprintf("%v|%s|%s", params.Urls, params.Branch, params.ChartPath)
+}
+
+// fetchFromGitCache checks out the chart path of the branch from the cache, in a clean DestDir
+func fetchFromGitCache(req *ChartRequest, location string, params *appv1.Git) (string, error) {
+	if err := os.RemoveAll(req.DestDir); err != nil {
+		klog.Error(err, "- Failed to remove all: ", req.DestDir)
+	}
+
+	if err := os.MkdirAll(req.DestDir, 0750); err != nil {
+		return "", err
+	}
+
+	options, transport, err := gitCloneOptions(req.ConfigMap, req.Secret, req.DestDir, location, params.Branch,
+		req.HelmRelease.Repo.InsecureSkipVerify)
+	if err != nil {
+		return "", err
+	}
+
+	return checkoutFromGitCache(options, transport, req.CacheDir, req.DestDir, params.ChartPath,
+		!params.DisableSubmodules)
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func init() {
+	RegisterChartSource(appv1.HelmRepoSourceType, helmRepoChartSource{})
+}
+
+// helmRepoChartSource downloads chart archives from a helm repo or a local file
+type helmRepoChartSource struct{}
+
+func (helmRepoChartSource) Resolve(source *appv1.Source) ([]string, error) {
+	if source.HelmRepo == nil {
+		return nil, fmt.Errorf("helmrepo type but Spec.HelmRepo is not defined")
+	}
+
+	return source.HelmRepo.Urls, nil
+}
+
+func (helmRepoChartSource) Fetch(req *ChartRequest, location string) (*Chart, error) {
+	chartZip, err := downloadChartArchive(req.ConfigMap, req.Secret, req.DestDir, req.HelmRelease, location)
+	if err != nil {
+		return nil, err
+	}
+
+	signers := []string{}
+
+	if req.Source.HelmRepo.Verify {
+		signedBy, err := verifyChartProvenance(req, location, chartZip)
+		if err != nil {
+			return nil, err
+		}
+
+		signers = append(signers, signedBy)
+	}
+
+	if req.Source.HelmRepo.Cosign != nil {
+		signedBy, err := verifyCosignBlob(req, location, chartZip)
+		if err != nil {
+			return nil, err
+		}
+
+		signers = append(signers, signedBy)
+	}
+
+	chartDir, revision, err := expandChartArchive(req.DestDir, req.HelmRelease.Repo.ChartName, chartZip, location)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Chart{Dir: chartDir, Location: location, Revision: revision,
+		SignedBy: strings.Join(signers, ", ")}, nil
+}
+
+func (helmRepoChartSource) Describe(source *appv1.Source) string {
+	if source.HelmRepo == nil {
+		return string(source.SourceType)
+	}
+
+	return fmt.Sprintf("%v", source.HelmRepo.Urls)
+}
@@ -0,0 +1,479 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/klog"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const (
+	ociScheme             = "oci://"
+	ociManifestMediaType  = "application/vnd.oci.image.manifest.v1+json"
+	helmChartContentMedia = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	// ociMaxManifestSize bounds the manifests and the cosign payloads read in memory
+	ociMaxManifestSize = 4 << 20
+)
+
+var errOCINotFound = errors.New("oci manifest not found")
+
+func init() {
+	RegisterChartSource(appv1.OCISourceType, ociChartSource{})
+}
+
+// ociChartSource pulls the charts pushed to an OCI registry by helm push. The registry is reached over https
+// with the credential of the secret, exchanged for a bearer token when the registry asks for one.
+type ociChartSource struct{}
+
+func (ociChartSource) Resolve(source *appv1.Source) ([]string, error) {
+	if source.OCI == nil {
+		return nil, fmt.Errorf("oci type but Source.OCI is not defined")
+	}
+
+	if !strings.HasPrefix(source.OCI.Repository, ociScheme) {
+		return nil, fmt.Errorf("oci type, Source.OCI.Repository %q must start with %s", source.OCI.Repository,
+			ociScheme)
+	}
+
+	return []string{source.OCI.Repository}, nil
+}
+
+func (ociChartSource) Fetch(req *ChartRequest, location string) (*Chart, error) {
+	tag := req.Source.OCI.Tag
+	if tag == "" {
+		// helm push replaces the + of the semver build metadata, it is not allowed in tags
+		tag = strings.ReplaceAll(req.HelmRelease.Repo.Version, "+", "_")
+	}
+
+	if tag == "" {
+		return nil, fmt.Errorf("oci type, need Source.OCI.Tag or Repo.Version to be populated")
+	}
+
+	client, err := newOCIClient(req, location)
+	if err != nil {
+		return nil, err
+	}
+
+	reference := location + ":" + tag
+
+	manifest, digest, err := client.manifest(tag)
+	if err != nil {
+		klog.Error(err, " - reference: ", reference)
+		return nil, err
+	}
+
+	var layer *ociDescriptor
+
+	for i := range manifest.Layers {
+		if manifest.Layers[i].MediaType == helmChartContentMedia {
+			layer = &manifest.Layers[i]
+			break
+		}
+	}
+
+	if layer == nil {
+		return nil, fmt.Errorf("%s has no layer of media type %s", reference, helmChartContentMedia)
+	}
+
+	// the archive is named after its digest, it does not change once downloaded. The name only has a prefix of
+	// the digest, a downloaded archive is reused only if it matches the digest of the layer, that the signature of
+	// the manifest covers
+	chartZip := filepath.Join(req.DestDir, fmt.Sprintf("%s-%s.tgz", path.Base(client.repository),
+		digestTrim(strings.TrimPrefix(layer.Digest, "sha256:"))))
+
+	if zipDigest, err := fileDigest(chartZip); err == nil && zipDigest == layer.Digest {
+		klog.V(5).Info("Skip download chartZip already exists: ", chartZip)
+	} else if err := client.downloadBlob(layer.Digest, chartZip); err != nil {
+		klog.Error(err, " - reference: ", reference)
+		return nil, err
+	}
+
+	signedBy := ""
+
+	if req.Source.OCI.Cosign != nil {
+		if signedBy, err = verifyCosignManifest(req, client, reference, digest); err != nil {
+			return nil, err
+		}
+	}
+
+	chartDir, _, err := expandChartArchive(req.DestDir, req.HelmRelease.Repo.ChartName, chartZip, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Chart{Dir: chartDir, Location: reference, Revision: digest, SignedBy: signedBy}, nil
+}
+
+func (ociChartSource) Describe(source *appv1.Source) string {
+	if source.OCI == nil {
+		return string(source.SourceType)
+	}
+
+	if source.OCI.Tag != "" {
+		return source.OCI.Repository + ":" + source.OCI.Tag
+	}
+
+	return source.OCI.Repository
+}
+
+// ociDescriptor describes the content of a manifest
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ociManifest is an OCI image manifest
+type ociManifest struct {
+	MediaType string          `json:"mediaType,omitempty"`
+	Config    ociDescriptor   `json:"config"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// ociClient pulls the manifests and blobs of one repository of a registry
+type ociClient struct {
+	httpClient *http.Client
+	registry   string
+	repository string
+	credential *RepoCredential
+	token      string
+}
+
+func newOCIClient(req *ChartRequest, location string) (*ociClient, error) {
+	u, err := url.Parse("https://" + strings.TrimPrefix(location, ociScheme))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the oci repository %s: %w", location, err)
+	}
+
+	repository := strings.Trim(u.Path, "/")
+	if u.Host == "" || repository == "" {
+		return nil, fmt.Errorf("the oci repository %s has no registry or no repository", location)
+	}
+
+	registryURL := "https://" + u.Host + "/" + repository
+
+	httpClient, err := newSourceHTTPClient(req.ConfigMap, req.Secret, registryURL, req.HelmRelease.Repo.InsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := httpCredential(req.Secret, registryURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ociClient{httpClient: httpClient, registry: u.Host, repository: repository, credential: credential}, nil
+}
+
+// manifest returns the manifest of the tag or digest and its digest, errOCINotFound if it does not exist
+func (c *ociClient) manifest(reference string) (*ociManifest, string, error) {
+	body, err := c.get("/manifests/"+reference, ociManifestMediaType)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer body.Close()
+
+	content, err := ioutil.ReadAll(io.LimitReader(body, ociMaxManifestSize))
+	if err != nil {
+		return nil, "", err
+	}
+
+	manifest := &ociManifest{}
+	if err := json.Unmarshal(content, manifest); err != nil {
+		return nil, "", fmt.Errorf("failed to parse the manifest of %s:%s: %w", c.repository, reference, err)
+	}
+
+	return manifest, "sha256:" + hexSHA256(content), nil
+}
+
+// downloadBlob downloads the blob into dest and verifies its digest, dest is removed when it does not match
+func (c *ociClient) downloadBlob(digest, dest string) error {
+	body, err := c.get("/blobs/"+digest, "")
+	if err != nil {
+		return err
+	}
+
+	defer body.Close()
+
+	out, err := os.Create(filepath.Clean(dest))
+	if err != nil {
+		klog.Error(err, " - Failed to create: ", dest)
+		return err
+	}
+
+	h := sha256.New()
+
+	_, err = io.Copy(io.MultiWriter(out, h), body)
+	closeHelper(out)
+
+	if err == nil && "sha256:"+hex.EncodeToString(h.Sum(nil)) != digest {
+		err = fmt.Errorf("the blob %s of %s does not match its digest", digest, c.repository)
+	}
+
+	if err != nil {
+		_ = os.Remove(dest)
+	}
+
+	return err
+}
+
+// blob returns the content of the blob after verifying its digest
+func (c *ociClient) blob(digest string) ([]byte, error) {
+	body, err := c.get("/blobs/"+digest, "")
+	if err != nil {
+		return nil, err
+	}
+
+	defer body.Close()
+
+	content, err := ioutil.ReadAll(io.LimitReader(body, ociMaxManifestSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if "sha256:"+hexSHA256(content) != digest {
+		return nil, fmt.Errorf("the blob %s of %s does not match its digest", digest, c.repository)
+	}
+
+	return content, nil
+}
+
+// get returns the body of the path under /v2/<repository>. When the registry answers with a bearer
+// challenge, the credential is exchanged for a token and the request is sent again.
+func (c *ociClient) get(p, accept string) (io.ReadCloser, error) {
+	target := "https://" + c.registry + "/v2/" + c.repository + p
+
+	resp, err := c.do(target, accept)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	if resp.StatusCode == http.StatusUnauthorized && c.token == "" &&
+		strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		resp.Body.Close()
+
+		if err := c.fetchToken(challenge); err != nil {
+			return nil, err
+		}
+
+		if resp, err = c.do(target, accept); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		return resp.Body, nil
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s%s", errOCINotFound, c.repository, p)
+	}
+
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	return nil, fmt.Errorf("return code: %d unable to get %s%s: %s", resp.StatusCode, c.repository, p,
+		strings.TrimSpace(string(msg)))
+}
+
+func (c *ociClient) do(target, accept string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	switch {
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	case c.credential != nil:
+		c.credential.setAuth(req)
+	}
+
+	return c.httpClient.Do(req)
+}
+
+// fetchToken gets the pull token of the repository from the realm of the bearer challenge
+func (c *ociClient) fetchToken(challenge string) error {
+	params := parseAuthChallenge(challenge[len("bearer "):])
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return fmt.Errorf("invalid bearer challenge of %s: %s", c.registry, challenge)
+	}
+
+	query := realm.Query()
+
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + c.repository + ":pull"
+	}
+
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	if c.credential != nil {
+		c.credential.setAuth(req)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("return code: %d unable to get the token of %s from %s", resp.StatusCode, c.repository,
+			realm.Host)
+	}
+
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, ociMaxManifestSize)).Decode(&token); err != nil {
+		return fmt.Errorf("failed to parse the token of %s: %w", c.repository, err)
+	}
+
+	c.token = token.Token
+	if c.token == "" {
+		c.token = token.AccessToken
+	}
+
+	if c.token == "" {
+		return fmt.Errorf("no token for %s from %s", c.repository, realm.Host)
+	}
+
+	return nil
+}
+
+// parseAuthChallenge returns the parameters of a challenge, for example
+// realm="https://auth.example.com/token",service="registry.example.com"
+func parseAuthChallenge(s string) map[string]string {
+	params := map[string]string{}
+
+	for s != "" {
+		var key, value string
+
+		key, s, _ = CutString(s, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+
+		if strings.HasPrefix(s, `"`) {
+			value, s, _ = CutString(s[1:], `"`)
+			_, s, _ = CutString(s, ",")
+		} else {
+			value, s, _ = CutString(s, ",")
+		}
+
+		if key != "" {
+			params[key] = strings.TrimSpace(value)
+		}
+	}
+
+	return params
+}
+
+// verifyCosignManifest verifies that the registry holds a cosign signature of the manifest digest signed by the
+// cosign public key of the oci source. It returns the identity of the key.
+func verifyCosignManifest(req *ChartRequest, client *ociClient, reference, digest string) (string, error) {
+	key, signedBy, err := cosignPublicKey(req, req.Source.OCI.Cosign)
+	if err != nil {
+		return "", err
+	}
+
+	// cosign stores the signatures of sha256:<hex> under the tag sha256-<hex>.sig
+	signatures, _, err := client.manifest(strings.Replace(digest, ":", "-", 1) + ".sig")
+	if err != nil {
+		return "", fmt.Errorf("%w: no cosign signature of %s: %v", ErrChartNotVerified, reference, err)
+	}
+
+	for _, layer := range signatures.Layers {
+		signature := layer.Annotations[cosignSignatureAnnotation]
+		if signature == "" {
+			continue
+		}
+
+		payload, err := client.blob(layer.Digest)
+		if err != nil {
+			return "", fmt.Errorf("%w: failed to get the cosign signature payload of %s: %v", ErrChartNotVerified,
+				reference, err)
+		}
+
+		if err := verifyCosignSignature(key, payload, signature); err != nil {
+			klog.V(3).Info("Skip cosign signature of ", reference, ": ", err)
+			continue
+		}
+
+		simpleSigning := struct {
+			Critical struct {
+				Image struct {
+					DockerManifestDigest string `json:"docker-manifest-digest"`
+				} `json:"image"`
+			} `json:"critical"`
+		}{}
+
+		if err := json.Unmarshal(payload, &simpleSigning); err != nil {
+			return "", fmt.Errorf("%w: failed to parse the cosign signature payload of %s: %v", ErrChartNotVerified,
+				reference, err)
+		}
+
+		if simpleSigning.Critical.Image.DockerManifestDigest != digest {
+			return "", fmt.Errorf("%w: the cosign signature of %s signs %s instead of %s", ErrChartNotVerified,
+				reference, simpleSigning.Critical.Image.DockerManifestDigest, digest)
+		}
+
+		klog.Info("Verified the cosign signature of chart ", reference, "@", digest, " signed by ", signedBy)
+
+		return signedBy, nil
+	}
+
+	return "", fmt.Errorf("%w: no cosign signature of %s is signed by the cosign public key", ErrChartNotVerified,
+		reference)
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// ociRegistryStandIn serves the manifests and blobs of the repositories pushed to it. The requests need the
+// token that the /token endpoint hands out for user:password.
+type ociRegistryStandIn struct {
+	manifests map[string][]byte
+	blobs     map[string][]byte
+}
+
+func newOCIRegistryStandIn() (*ociRegistryStandIn, *httptest.Server) {
+	registry := &ociRegistryStandIn{manifests: map[string][]byte{}, blobs: map[string][]byte{}}
+
+	var server *httptest.Server
+
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "password" ||
+				!strings.HasPrefix(r.URL.Query().Get("scope"), "repository:") {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			_, _ = w.Write([]byte(`{"token":"pull-token"}`))
+
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer pull-token" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		content, ok := registry.manifests[r.URL.Path]
+		if !ok {
+			content, ok = registry.blobs[r.URL.Path]
+		}
+
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write(content)
+	}))
+
+	return registry, server
+}
+
+// pushBlob stores the blob in the repository and returns its descriptor
+func (r *ociRegistryStandIn) pushBlob(repository, mediaType string, content []byte) ociDescriptor {
+	digest := "sha256:" + hexSHA256(content)
+	r.blobs["/v2/"+repository+"/blobs/"+digest] = content
+
+	return ociDescriptor{MediaType: mediaType, Digest: digest, Size: int64(len(content))}
+}
+
+// pushManifest stores the manifest of the layers under the tag and returns its digest
+func (r *ociRegistryStandIn) pushManifest(t *testing.T, repository, tag string, layers ...ociDescriptor) string {
+	manifest, err := json.Marshal(&ociManifest{
+		MediaType: ociManifestMediaType,
+		Config:    r.pushBlob(repository, "application/vnd.cncf.helm.config.v1+json", []byte("{}")),
+		Layers:    layers,
+	})
+	assert.NoError(t, err)
+
+	digest := "sha256:" + hexSHA256(manifest)
+	r.manifests["/v2/"+repository+"/manifests/"+tag] = manifest
+	r.manifests["/v2/"+repository+"/manifests/"+digest] = manifest
+
+	return digest
+}
+
+func TestFetchChartOCI(t *testing.T) {
+	chartZip, err := ioutil.ReadFile("../../test/helmrepo/subscription-release-test-1-0.1.0.tgz")
+	assert.NoError(t, err)
+
+	registry, server := newOCIRegistryStandIn()
+	defer server.Close()
+
+	key, publicKey := newCosignKey(t)
+	otherKey, _ := newCosignKey(t)
+
+	// 0.1.0+1 is signed by the key, 0.2.0 by another key, 0.3.0 is not signed and 0.5.0 has no chart
+	layer := registry.pushBlob("charts/test", helmChartContentMedia, chartZip)
+	signed := registry.pushManifest(t, "charts/test", "0.1.0_1", layer)
+	signedByOther := registry.pushManifest(t, "charts/test", "0.2.0",
+		layer, registry.pushBlob("charts/test", "application/vnd.oci.image.layer.v1.tar", []byte("other")))
+	registry.pushManifest(t, "charts/test", "0.3.0",
+		layer, registry.pushBlob("charts/test", "application/vnd.oci.image.layer.v1.tar", []byte("unsigned")))
+	registry.pushManifest(t, "charts/test", "0.5.0",
+		registry.pushBlob("charts/test", "application/vnd.oci.image.layer.v1.tar", []byte("not a chart")))
+
+	for digest, key := range map[string]*ecdsa.PrivateKey{signed: key, signedByOther: otherKey} {
+		payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"%s/charts/test"},`+
+			`"image":{"docker-manifest-digest":"%s"},"type":"cosign container image signature"},"optional":null}`,
+			server.Listener.Addr(), digest))
+
+		signature := registry.pushBlob("charts/test", "application/vnd.dev.cosign.simplesigning.v1+json", payload)
+		signature.Annotations = map[string]string{cosignSignatureAnnotation: cosignSign(t, key, payload)}
+
+		registry.pushManifest(t, "charts/test", strings.Replace(digest, ":", "-", 1)+".sig", signature)
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "cosign", Namespace: "default"},
+			Data:       map[string][]byte{cosignPublicKeyKey: publicKey},
+		},
+	).Build()
+
+	configMap := &corev1.ConfigMap{Data: map[string]string{caCertsKey: string(serverCACert(server))}}
+	secret := &corev1.Secret{Data: map[string][]byte{"user": []byte("user"), "password": []byte("password")}}
+
+	tests := []struct {
+		name     string
+		version  string
+		cosign   bool
+		revision string
+		err      string
+	}{
+		{name: "tag of the version", version: "0.1.0+1", revision: signed},
+		{name: "signed", version: "0.1.0+1", cosign: true, revision: signed},
+		{name: "signed by another key", version: "0.2.0", cosign: true, err: "is signed by the cosign public key"},
+		{name: "no signature", version: "0.3.0", cosign: true, err: "no cosign signature"},
+		{name: "no chart layer", version: "0.5.0", err: "has no layer of media type"},
+		{name: "no tag", version: "0.4.0", err: errOCINotFound.Error()},
+	}
+
+	for _, tt := range tests {
+		dir, err := ioutil.TempDir("/tmp", "charts")
+		assert.NoError(t, err)
+
+		defer os.RemoveAll(dir)
+
+		oci := &appv1.OCI{Repository: "oci://" + server.Listener.Addr().String() + "/charts/test"}
+		if tt.cosign {
+			oci.Cosign = &appv1.CosignVerification{SecretRef: &corev1.ObjectReference{Name: "cosign"}}
+		}
+
+		chart, err := FetchChartWithClient(c, configMap, secret, dir, &appv1.HelmRelease{
+			ObjectMeta: metav1.ObjectMeta{Name: "subscription-release-test-1-cr", Namespace: "default"},
+			Repo: appv1.HelmReleaseRepo{
+				Source:    &appv1.Source{SourceType: appv1.OCISourceType, OCI: oci},
+				ChartName: "subscription-release-test-1",
+				Version:   tt.version,
+			},
+		})
+
+		if tt.revision == "" {
+			if assert.Error(t, err, tt.name) {
+				assert.Contains(t, err.Error(), tt.err, tt.name)
+				assert.Equal(t, tt.cosign, errors.Is(err, ErrChartNotVerified), tt.name)
+			}
+
+			continue
+		}
+
+		if !assert.NoError(t, err, tt.name) {
+			continue
+		}
+
+		assert.Equal(t, tt.revision, chart.Revision, tt.name)
+
+		_, err = os.Stat(filepath.Join(chart.Dir, "Chart.yaml"))
+		assert.NoError(t, err, tt.name)
+
+		if tt.cosign {
+			assert.True(t, strings.HasPrefix(chart.SignedBy, "sha256:"), tt.name)
+		} else {
+			assert.Empty(t, chart.SignedBy, tt.name)
+		}
+	}
+
+	// a stale archive with the name of the layer is replaced by the signed layer
+	dir, err := ioutil.TempDir("/tmp", "charts")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	destDir := filepath.Join(dir, "subscription-release-test-1-cr", "default", "subscription-release-test-1")
+	assert.NoError(t, os.MkdirAll(destDir, 0750))
+
+	staleZip := filepath.Join(destDir, "test-"+digestTrim(strings.TrimPrefix(layer.Digest, "sha256:"))+".tgz")
+	assert.NoError(t, ioutil.WriteFile(staleZip, []byte("stale"), 0600))
+
+	_, err = FetchChartWithClient(c, configMap, secret, dir, &appv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "subscription-release-test-1-cr", Namespace: "default"},
+		Repo: appv1.HelmReleaseRepo{
+			Source: &appv1.Source{SourceType: appv1.OCISourceType, OCI: &appv1.OCI{
+				Repository: "oci://" + server.Listener.Addr().String() + "/charts/test",
+				Cosign:     &appv1.CosignVerification{SecretRef: &corev1.ObjectReference{Name: "cosign"}},
+			}},
+			ChartName: "subscription-release-test-1",
+			Version:   "0.1.0+1",
+		},
+	})
+	assert.NoError(t, err)
+
+	zipDigest, err := fileDigest(staleZip)
+	assert.NoError(t, err)
+	assert.Equal(t, layer.Digest, zipDigest)
+}
+
+func TestParseAuthChallenge(t *testing.T) {
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry.example.com",
+		"scope":   "repository:charts/test:pull,push",
+	}, parseAuthChallenge(`realm="https://auth.example.com/token",service=registry.example.com,`+
+		`scope="repository:charts/test:pull,push"`))
+}
@@ -0,0 +1,425 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"helm.sh/helm/v3/pkg/repo"
+	"k8s.io/klog"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const (
+	s3DefaultRegion = "us-east-1"
+	s3Algorithm     = "AWS4-HMAC-SHA256"
+	s3Service       = "s3"
+	// the sha256 of an empty payload, the GET requests have no body
+	s3EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+var errS3NotFound = errors.New("s3 object not found")
+
+func init() {
+	RegisterChartSource(appv1.S3SourceType, s3ChartSource{})
+}
+
+// s3ChartSource downloads chart archives from an S3-compatible bucket, for example MinIO.
+// The requests use the path-style addressing and the AWS signature version 4.
+type s3ChartSource struct{}
+
+func (s3ChartSource) Resolve(source *appv1.Source) ([]string, error) {
+	if source.S3 == nil {
+		return nil, fmt.Errorf("s3 type but Source.S3 is not defined")
+	}
+
+	if source.S3.Endpoint == "" || source.S3.Bucket == "" {
+		return nil, fmt.Errorf("s3 type, need Source.S3.Endpoint and Source.S3.Bucket to be populated")
+	}
+
+	return []string{source.S3.Endpoint}, nil
+}
+
+func (s3ChartSource) Fetch(req *ChartRequest, location string) (*Chart, error) {
+	client, err := newS3Client(req, location)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := client.chartKey(req.Source.S3, req.HelmRelease.Repo.ChartName, req.HelmRelease.Repo.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	objectURL := client.objectURL(key).String()
+
+	chartZip := filepath.Join(req.DestDir, path.Base(key))
+	if err := client.download(key, chartZip); err != nil {
+		klog.Error(err, " - url: ", objectURL)
+		return nil, err
+	}
+
+	chartDir, revision, err := expandChartArchive(req.DestDir, req.HelmRelease.Repo.ChartName, chartZip, objectURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Chart{Dir: chartDir, Location: objectURL, Revision: revision}, nil
+}
+
+func (s3ChartSource) Describe(source *appv1.Source) string {
+	if source.S3 == nil {
+		return string(source.SourceType)
+	}
+
+	return fmt.Sprintf("%s|%s|%s%s", source.S3.Endpoint, source.S3.Bucket, source.S3.KeyPrefix, source.S3.Key)
+}
+
+// s3Client gets the objects of one bucket
+type s3Client struct {
+	httpClient      *http.Client
+	endpoint        *url.URL
+	bucket          string
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	now             func() time.Time
+}
+
+func newS3Client(req *ChartRequest, endpoint string) (*s3Client, error) {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the s3 endpoint %s: %w", endpoint, err)
+	}
+
+	httpClient, err := newSourceHTTPClient(req.ConfigMap, req.Secret, endpoint, req.HelmRelease.Repo.InsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &s3Client{
+		httpClient: httpClient,
+		endpoint:   u,
+		bucket:     req.Source.S3.Bucket,
+		region:     req.Source.S3.Region,
+		now:        time.Now,
+	}
+
+	if client.region == "" {
+		client.region = s3DefaultRegion
+	}
+
+	if req.Secret != nil && req.Secret.Data != nil {
+		client.accessKeyID = strings.TrimSpace(string(req.Secret.Data["accessKeyID"]))
+		client.secretAccessKey = strings.TrimSpace(string(req.Secret.Data["secretAccessKey"]))
+		client.sessionToken = strings.TrimSpace(string(req.Secret.Data["sessionToken"]))
+	}
+
+	return client, nil
+}
+
+// chartKey returns the key of the chart archive. Without explicit key the archive is looked up in the
+// index.yaml under the key prefix, if any, and defaults to <keyPrefix><chartName>-<version>.tgz
+func (c *s3Client) chartKey(s3 *appv1.S3, chartName, version string) (string, error) {
+	if s3.Key != "" {
+		return s3.Key, nil
+	}
+
+	defaultKey := s3.KeyPrefix + chartName + "-" + version + ".tgz"
+
+	body, err := c.getObject(s3.KeyPrefix + "index.yaml")
+	if errors.Is(err, errS3NotFound) {
+		klog.V(3).Info("No index.yaml in s3 bucket ", c.bucket, " under ", s3.KeyPrefix, ", using key ", defaultKey)
+
+		return defaultKey, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	defer body.Close()
+
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+
+	index := &repo.IndexFile{}
+	if err := yaml.Unmarshal(b, index); err != nil {
+		return "", fmt.Errorf("failed to parse the index.yaml of s3 bucket %s: %w", c.bucket, err)
+	}
+
+	index.SortEntries()
+
+	chartVersion, err := index.Get(chartName, version)
+	if err != nil {
+		return "", fmt.Errorf("chart %s version %s not found in the index.yaml of s3 bucket %s: %w",
+			chartName, version, c.bucket, err)
+	}
+
+	if len(chartVersion.URLs) == 0 {
+		return "", fmt.Errorf("chart %s version %s has no url in the index.yaml of s3 bucket %s",
+			chartName, version, c.bucket)
+	}
+
+	return c.keyFromIndexURL(s3.KeyPrefix, chartVersion.URLs[0])
+}
+
+// keyFromIndexURL returns the key of a chart url of the index. The relative urls are relative to the
+// key prefix, the absolute urls must point to an object of the bucket.
+func (c *s3Client) keyFromIndexURL(keyPrefix, chartURL string) (string, error) {
+	u, err := url.Parse(chartURL)
+	if err != nil {
+		return "", err
+	}
+
+	if !u.IsAbs() {
+		return keyPrefix + strings.TrimPrefix(u.Path, "/"), nil
+	}
+
+	bucketPath := "/" + c.bucket + "/"
+	if !strings.HasPrefix(u.Path, bucketPath) {
+		return "", fmt.Errorf("chart url %s of the index.yaml is not in s3 bucket %s", chartURL, c.bucket)
+	}
+
+	return strings.TrimPrefix(u.Path, bucketPath), nil
+}
+
+func (c *s3Client) download(key, dest string) error {
+	body, err := c.getObject(key)
+	if err != nil {
+		return err
+	}
+
+	defer body.Close()
+
+	out, err := os.Create(filepath.Clean(dest))
+	if err != nil {
+		klog.Error(err, " - Failed to create: ", dest)
+		return err
+	}
+
+	defer closeHelper(out)
+
+	_, err = io.Copy(out, body)
+
+	return err
+}
+
+func (c *s3Client) objectURL(key string) *url.URL {
+	objectPath := path.Join("/", c.bucket, key)
+
+	return &url.URL{
+		Scheme:  c.endpoint.Scheme,
+		Host:    c.endpoint.Host,
+		Path:    objectPath,
+		RawPath: s3EscapePath(objectPath),
+	}
+}
+
+// getObject returns the body of the object, errS3NotFound if the object does not exist
+func (c *s3Client) getObject(key string) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, c.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.sign(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		return resp.Body, nil
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s/%s", errS3NotFound, c.bucket, key)
+	}
+
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	return nil, fmt.Errorf("return code: %d unable to get s3 object %s/%s: %s", resp.StatusCode, c.bucket, key,
+		strings.TrimSpace(string(msg)))
+}
+
+// sign adds the AWS signature version 4 headers to a request without body. Anonymous requests are not signed.
+func (c *s3Client) sign(req *http.Request) {
+	if c.accessKeyID == "" || c.secretAccessKey == "" {
+		return
+	}
+
+	now := c.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", s3EmptyPayloadHash)
+
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+
+	scope := strings.Join([]string{date, c.region, s3Service, "aws4_request"}, "/")
+	signedHeaders, canonicalRequest := s3CanonicalRequest(req)
+
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		amzDate,
+		scope,
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+c.secretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, c.region)
+	signingKey = hmacSHA256(signingKey, s3Service)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, c.accessKeyID, scope, signedHeaders, signature))
+}
+
+// s3CanonicalRequest returns the signed headers and the canonical request of a request without body
+func s3CanonicalRequest(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "host" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	return signedHeaders, canonicalRequest
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	params := []string{}
+
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+
+		for _, v := range values {
+			params = append(params, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+
+	return strings.Join(params, "&")
+}
+
+// s3EscapePath escapes the segments of an object path as required by the signature version 4
+func s3EscapePath(p string) string {
+	return s3Escape(p, false)
+}
+
+// s3Escape escapes all the characters but the unreserved ones, and '/' unless encodeSlash is true
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	h := sha256.Sum256(data)
+
+	return hex.EncodeToString(h[:])
+}
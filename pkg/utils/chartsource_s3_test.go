@@ -0,0 +1,183 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const (
+	testS3AccessKeyID     = "AKIDEXAMPLE"
+	testS3SecretAccessKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+)
+
+// newS3StandIn serves the objects of the bucket "charts" and rejects the requests that are not signed
+// with the test credentials
+func newS3StandIn(objects map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, s3Algorithm+" Credential="+testS3AccessKeyID+"/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		// recompute the signature from the canonical request
+		date := r.Header.Get("X-Amz-Date")[:8]
+		scope := date + "/eu-west-1/s3/aws4_request"
+
+		if !strings.Contains(auth, scope) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		_, canonicalRequest := s3CanonicalRequest(r)
+		stringToSign := strings.Join([]string{s3Algorithm, r.Header.Get("X-Amz-Date"), scope,
+			hexSHA256([]byte(canonicalRequest))}, "\n")
+
+		key := hmacSHA256([]byte("AWS4"+testS3SecretAccessKey), date)
+		for _, s := range []string{"eu-west-1", "s3", "aws4_request"} {
+			key = hmacSHA256(key, s)
+		}
+
+		if !strings.HasSuffix(auth, "Signature="+hex.EncodeToString(hmacSHA256(key, stringToSign))) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		object, ok := objects[strings.TrimPrefix(r.URL.Path, "/charts/")]
+		if !strings.HasPrefix(r.URL.Path, "/charts/") || !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(object))
+	}))
+}
+
+func newS3TestHelmRelease(endpoint string, s3 *appv1.S3) *appv1.HelmRelease {
+	s3.Endpoint = endpoint
+	s3.Bucket = "charts"
+	s3.Region = "eu-west-1"
+
+	return &appv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "subscription-release-test-1-cr",
+			Namespace: "default",
+		},
+		Repo: appv1.HelmReleaseRepo{
+			Source: &appv1.Source{
+				SourceType: appv1.S3SourceType,
+				S3:         s3,
+			},
+			ChartName: "subscription-release-test-1",
+			Version:   "0.1.0",
+		},
+	}
+}
+
+func TestFetchChartS3(t *testing.T) {
+	chartZip, err := ioutil.ReadFile("../../test/helmrepo/subscription-release-test-1-0.1.0.tgz")
+	assert.NoError(t, err)
+
+	index := `apiVersion: v1
+entries:
+  subscription-release-test-1:
+  - name: subscription-release-test-1
+    version: 0.1.0
+    urls:
+    - archives/test-1.tgz
+`
+
+	server := newS3StandIn(map[string]string{
+		"stable/index.yaml":                             index,
+		"stable/archives/test-1.tgz":                    string(chartZip),
+		"default/subscription-release-test-1-0.1.0.tgz": string(chartZip),
+	})
+	defer server.Close()
+
+	secret := &corev1.Secret{
+		Data: map[string][]byte{
+			"accessKeyID":     []byte(testS3AccessKeyID),
+			"secretAccessKey": []byte(testS3SecretAccessKey),
+		},
+	}
+
+	tests := []struct {
+		name string
+		s3   *appv1.S3
+		key  string
+	}{
+		{name: "index", s3: &appv1.S3{KeyPrefix: "stable/"}, key: "stable/archives/test-1.tgz"},
+		{name: "default key", s3: &appv1.S3{KeyPrefix: "default/"}, key: "default/subscription-release-test-1-0.1.0.tgz"},
+		{name: "key", s3: &appv1.S3{Key: "stable/archives/test-1.tgz"}, key: "stable/archives/test-1.tgz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("/tmp", "charts")
+			assert.NoError(t, err)
+
+			defer os.RemoveAll(dir)
+
+			hr := newS3TestHelmRelease(server.URL, tt.s3)
+
+			chart, err := FetchChart(nil, secret, dir, hr)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			_, err = os.Stat(filepath.Join(chart.Dir, "Chart.yaml"))
+			assert.NoError(t, err)
+			assert.Equal(t, server.URL+"/charts/"+tt.key, chart.Location)
+
+			digest, err := fileDigest("../../test/helmrepo/subscription-release-test-1-0.1.0.tgz")
+			assert.NoError(t, err)
+			assert.Equal(t, digest, chart.Revision)
+		})
+	}
+
+	dir, err := ioutil.TempDir("/tmp", "charts")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	// not signed
+	_, err = FetchChart(nil, nil, dir, newS3TestHelmRelease(server.URL, &appv1.S3{KeyPrefix: "stable/"}))
+	assert.Error(t, err)
+
+	// not in the bucket
+	_, err = FetchChart(nil, secret, dir, newS3TestHelmRelease(server.URL, &appv1.S3{KeyPrefix: "missing/"}))
+	assert.Error(t, err)
+}
+
+func TestS3Escape(t *testing.T) {
+	assert.Equal(t, "/charts/a%20b/c~d_e-f.tgz", s3EscapePath("/charts/a b/c~d_e-f.tgz"))
+	assert.Equal(t, "a%2Fb%3D", s3Escape("a/b=", true))
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// fakeChartSource fails on the locations listed in failing and returns the location as the revision
+type fakeChartSource struct {
+	failing map[string]bool
+}
+
+func (f fakeChartSource) Resolve(source *appv1.Source) ([]string, error) {
+	return source.HelmRepo.Urls, nil
+}
+
+func (f fakeChartSource) Fetch(req *ChartRequest, location string) (*Chart, error) {
+	if f.failing[location] {
+		return nil, errors.New("unreachable")
+	}
+
+	return &Chart{Dir: req.DestDir, Location: location, Revision: "rev-" + location}, nil
+}
+
+func (f fakeChartSource) Describe(source *appv1.Source) string {
+	return "fake"
+}
+
+func TestChartSourceRegistry(t *testing.T) {
+	const fakeSourceType appv1.SourceTypeEnum = "Fake"
+
+	RegisterChartSource(fakeSourceType, fakeChartSource{failing: map[string]bool{"first": true}})
+
+	_, err := GetChartSource("fake")
+	assert.NoError(t, err)
+
+	_, err = GetChartSource("unknown")
+	assert.Error(t, err)
+
+	dir, err := ioutil.TempDir("/tmp", "charts")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	hr := &appv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "fake-cr", Namespace: "default"},
+		Repo: appv1.HelmReleaseRepo{
+			Source: &appv1.Source{
+				SourceType: fakeSourceType,
+				HelmRepo:   &appv1.HelmRepo{Urls: []string{"first", "second"}},
+			},
+			ChartName: "fake",
+		},
+	}
+
+	chart, err := FetchChart(nil, nil, dir, hr)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", chart.Location)
+
+	status := ChartSourceStatus(hr.Repo.Source, chart)
+	assert.Equal(t, "fake", status.Description)
+	assert.Equal(t, "rev-second", status.Revision)
+}
+
+func TestFetchChartHelmRepoRevision(t *testing.T) {
+	hr := &appv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "subscription-release-test-1-cr",
+			Namespace: "default",
+		},
+		Repo: appv1.HelmReleaseRepo{
+			Source: &appv1.Source{
+				SourceType: appv1.HelmRepoSourceType,
+				HelmRepo: &appv1.HelmRepo{
+					Urls: []string{"file:../../test/helmrepo/subscription-release-test-1-0.1.0.tgz"},
+				},
+			},
+			ChartName: "subscription-release-test-1",
+		},
+	}
+	dir, err := ioutil.TempDir("/tmp", "charts")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	chart, err := FetchChart(nil, nil, dir, hr)
+	assert.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(chart.Dir, "Chart.yaml"))
+	assert.NoError(t, err)
+
+	digest, err := fileDigest("../../test/helmrepo/subscription-release-test-1-0.1.0.tgz")
+	assert.NoError(t, err)
+	assert.Equal(t, digest, chart.Revision)
+}
@@ -0,0 +1,232 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// newClientCertificate returns a CA and a client certificate and key signed by the CA, PEM encoded
+func newClientCertificate(t *testing.T) (*x509.CertPool, []byte, []byte) {
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	assert.NoError(t, err)
+
+	ca, err := x509.ParseCertificate(caDER)
+	assert.NoError(t, err)
+
+	clientKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	clientTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "test-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTemplate, ca, &clientKey.PublicKey, caKey)
+	assert.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	return pool,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(clientKey)})
+}
+
+// newMTLSServer starts a TLS server that requires a client certificate signed by the CA
+func newMTLSServer(handler http.Handler, clientCAs *x509.CertPool) *httptest.Server {
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+	server.StartTLS()
+
+	return server
+}
+
+func serverCACert(server *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+}
+
+func TestFetchChartHelmRepoClientCertificate(t *testing.T) {
+	chartZip, err := ioutil.ReadFile("../../test/helmrepo/subscription-release-test-1-0.1.0.tgz")
+	assert.NoError(t, err)
+
+	clientCAs, certPEM, keyPEM := newClientCertificate(t)
+
+	server := newMTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(chartZip)
+	}), clientCAs)
+	defer server.Close()
+
+	fetch := func(secret *corev1.Secret) error {
+		dir, err := ioutil.TempDir("/tmp", "charts")
+		assert.NoError(t, err)
+
+		defer os.RemoveAll(dir)
+
+		_, err = FetchChart(nil, secret, dir, &appv1.HelmRelease{
+			ObjectMeta: metav1.ObjectMeta{Name: "subscription-release-test-1-cr", Namespace: "default"},
+			Repo: appv1.HelmReleaseRepo{
+				Source: &appv1.Source{
+					SourceType: appv1.HelmRepoSourceType,
+					HelmRepo:   &appv1.HelmRepo{Urls: []string{server.URL + "/subscription-release-test-1-0.1.0.tgz"}},
+				},
+				ChartName: "subscription-release-test-1",
+			},
+		})
+
+		return err
+	}
+
+	assert.NoError(t, fetch(&corev1.Secret{
+		Data: map[string][]byte{
+			corev1.TLSCertKey:              certPEM,
+			corev1.TLSPrivateKeyKey:        keyPEM,
+			corev1.ServiceAccountRootCAKey: serverCACert(server),
+		},
+	}))
+
+	// the server rejects the requests without client certificate
+	assert.Error(t, fetch(&corev1.Secret{
+		Data: map[string][]byte{corev1.ServiceAccountRootCAKey: serverCACert(server)},
+	}))
+}
+
+// newGitHTTPBackend returns a git-http-backend handler serving a repo.git with the chart charts/test on
+// branch master, and the directory of the repo to remove
+func newGitHTTPBackend(t *testing.T) (http.Handler, string) {
+	execPath, err := exec.Command("git", "--exec-path").Output()
+	if err != nil {
+		t.Skip("git is not available")
+	}
+
+	backend := filepath.Join(strings.TrimSpace(string(execPath)), "git-http-backend")
+	if _, err := os.Stat(backend); err != nil {
+		t.Skip("git-http-backend is not available")
+	}
+
+	repoDir, err := ioutil.TempDir("/tmp", "gitrepo")
+	assert.NoError(t, err)
+
+	r, err := git.PlainInit(filepath.Join(repoDir, "work"), false)
+	assert.NoError(t, err)
+
+	commitFiles(t, r, filepath.Join(repoDir, "work"), map[string]string{
+		"charts/test/Chart.yaml": "apiVersion: v1\nname: test\nversion: 0.1.0\n",
+	})
+
+	_, err = git.PlainClone(filepath.Join(repoDir, "repo.git"), true, &git.CloneOptions{URL: filepath.Join(repoDir, "work")})
+	assert.NoError(t, err)
+
+	return &cgi.Handler{
+		Path: backend,
+		Env:  []string{"GIT_PROJECT_ROOT=" + repoDir, "GIT_HTTP_EXPORT_ALL=1"},
+	}, repoDir
+}
+
+func TestFetchChartGitClientCertificate(t *testing.T) {
+	backend, repoDir := newGitHTTPBackend(t)
+	defer os.RemoveAll(repoDir)
+
+	clientCAs, certPEM, keyPEM := newClientCertificate(t)
+
+	server := newMTLSServer(backend, clientCAs)
+	defer server.Close()
+
+	hr := &appv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "git-mtls", Namespace: "default"},
+		Repo: appv1.HelmReleaseRepo{
+			Source: &appv1.Source{
+				SourceType: appv1.GitSourceType,
+				Git: &appv1.Git{
+					Urls:      []string{server.URL + "/repo.git"},
+					ChartPath: "charts/test",
+					Branch:    "master",
+				},
+			},
+			ChartName: "test",
+		},
+	}
+
+	chartsDir, err := ioutil.TempDir("/tmp", "charts")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(chartsDir)
+
+	chart, err := FetchChart(nil, &corev1.Secret{
+		Data: map[string][]byte{
+			corev1.TLSCertKey:              certPEM,
+			corev1.TLSPrivateKeyKey:        keyPEM,
+			corev1.ServiceAccountRootCAKey: serverCACert(server),
+		},
+	}, chartsDir, hr)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = os.Stat(filepath.Join(chart.Dir, "Chart.yaml"))
+	assert.NoError(t, err)
+
+	// the server rejects the requests without client certificate
+	_, err = DownloadChart(nil, &corev1.Secret{
+		Data: map[string][]byte{corev1.ServiceAccountRootCAKey: serverCACert(server)},
+	}, chartsDir, hr)
+	assert.Error(t, err)
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/klog"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const (
+	// cosignPublicKeyKey is the key of the secret or config map holding the PEM encoded cosign public key
+	cosignPublicKeyKey = "cosign.pub"
+	// cosignSignatureAnnotation is the annotation of the layers of a cosign signature manifest holding the
+	// base64 encoded signature of the layer
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+)
+
+// cosignPublicKey reads the public key of the cosign verification. It returns the key and its identity, the
+// sha256 of its DER encoding.
+func cosignPublicKey(req *ChartRequest, cosign *appv1.CosignVerification) (crypto.PublicKey, string, error) {
+	if req.Client == nil {
+		return nil, "", fmt.Errorf("%w: no client to read the cosign public key", ErrChartNotVerified)
+	}
+
+	var keyPEM []byte
+
+	switch {
+	case cosign.SecretRef != nil:
+		secret, err := GetSecret(req.Client, req.HelmRelease.Namespace, cosign.SecretRef)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: failed to get cosign secret %s: %v", ErrChartNotVerified,
+				cosign.SecretRef.Name, err)
+		}
+
+		keyPEM = secret.Data[cosignPublicKeyKey]
+	case cosign.ConfigMapRef != nil:
+		configMap, err := GetConfigMap(req.Client, req.HelmRelease.Namespace, cosign.ConfigMapRef)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: failed to get cosign config map %s: %v", ErrChartNotVerified,
+				cosign.ConfigMapRef.Name, err)
+		}
+
+		if configMap != nil {
+			keyPEM = []byte(configMap.Data[cosignPublicKeyKey])
+		}
+	default:
+		return nil, "", fmt.Errorf("%w: cosign has neither secretRef nor configMapRef", ErrChartNotVerified)
+	}
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, "", fmt.Errorf("%w: no PEM encoded %s", ErrChartNotVerified, cosignPublicKeyKey)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: failed to parse the cosign public key: %v", ErrChartNotVerified, err)
+	}
+
+	sum := sha256.Sum256(block.Bytes)
+
+	return key, "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// verifyCosignSignature verifies the base64 encoded signature of the payload with the ECDSA, RSA PKCS #1 v1.5
+// or Ed25519 public key, the ECDSA and RSA signatures are over the sha256 of the payload
+func verifyCosignSignature(key crypto.PublicKey, payload []byte, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return fmt.Errorf("failed to decode the signature: %w", err)
+	}
+
+	digest := sha256.Sum256(payload)
+
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, digest[:], sig) {
+			return errors.New("invalid ecdsa signature")
+		}
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, payload, sig) {
+			return errors.New("invalid ed25519 signature")
+		}
+	default:
+		return fmt.Errorf("unsupported cosign public key type %T", key)
+	}
+
+	return nil
+}
+
+// verifyCosignBlob downloads the detached signature of the chart archive downloaded from the location, at the
+// location with a .sig extension, and verifies it with the cosign public key of the helm repo. It returns the
+// identity of the key.
+func verifyCosignBlob(req *ChartRequest, location, chartZip string) (string, error) {
+	key, signedBy, err := cosignPublicKey(req, req.Source.HelmRepo.Cosign)
+	if err != nil {
+		return "", err
+	}
+
+	sigFile, err := downloadChartArchive(req.ConfigMap, req.Secret, req.DestDir, req.HelmRelease, location+".sig")
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to download the cosign signature of %s: %v", ErrChartNotVerified, location, err)
+	}
+
+	signature, err := ioutil.ReadFile(filepath.Clean(sigFile))
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := ioutil.ReadFile(filepath.Clean(chartZip))
+	if err != nil {
+		return "", err
+	}
+
+	if err := verifyCosignSignature(key, payload, string(signature)); err != nil {
+		// the files that failed the verification are downloaded again on the next attempt
+		_ = os.Remove(sigFile)
+		_ = os.Remove(chartZip)
+
+		return "", fmt.Errorf("%w: cosign signature of %s: %v", ErrChartNotVerified, location, err)
+	}
+
+	klog.Info("Verified the cosign signature of chart ", location, " signed by ", signedBy)
+
+	return signedBy, nil
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// newCosignKey returns a new ECDSA P-256 key, like the keys of cosign generate-key-pair, and its PEM encoded
+// public key
+func newCosignKey(t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.NoError(t, err)
+
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// cosignSign returns the base64 encoded signature of the payload, like cosign sign-blob
+func cosignSign(t *testing.T, key *ecdsa.PrivateKey, payload []byte) string {
+	digest := sha256.Sum256(payload)
+
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	assert.NoError(t, err)
+
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func TestVerifyCosignSignature(t *testing.T) {
+	payload := []byte("chart")
+	digest := sha256.Sum256(payload)
+
+	ecdsaKey, _ := newCosignKey(t)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	rsaSig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	assert.NoError(t, err)
+
+	ed25519Public, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		key       crypto.PublicKey
+		signature string
+	}{
+		{name: "ecdsa", key: &ecdsaKey.PublicKey, signature: cosignSign(t, ecdsaKey, payload)},
+		{name: "rsa", key: &rsaKey.PublicKey, signature: base64.StdEncoding.EncodeToString(rsaSig)},
+		{name: "ed25519", key: ed25519Public,
+			signature: base64.StdEncoding.EncodeToString(ed25519.Sign(ed25519Key, payload))},
+	}
+
+	for _, tt := range tests {
+		assert.NoError(t, verifyCosignSignature(tt.key, payload, tt.signature+"\n"), tt.name)
+		assert.Error(t, verifyCosignSignature(tt.key, []byte("other chart"), tt.signature), tt.name)
+	}
+
+	assert.Error(t, verifyCosignSignature(&ecdsaKey.PublicKey, payload, "not base64"))
+}
+
+func TestFetchChartCosignBlob(t *testing.T) {
+	chartZip, err := ioutil.ReadFile("../../test/helmrepo/subscription-release-test-1-0.1.0.tgz")
+	assert.NoError(t, err)
+
+	key, publicKey := newCosignKey(t)
+	otherKey, _ := newCosignKey(t)
+
+	files := map[string][]byte{
+		"/subscription-release-test-1-0.1.0.tgz":           chartZip,
+		"/subscription-release-test-1-0.1.0.tgz.sig":       []byte(cosignSign(t, key, chartZip)),
+		"/other/subscription-release-test-1-0.1.0.tgz":     chartZip,
+		"/other/subscription-release-test-1-0.1.0.tgz.sig": []byte(cosignSign(t, otherKey, chartZip)),
+		"/unsigned/subscription-release-test-1-0.1.0.tgz":  chartZip,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "cosign", Namespace: "default"},
+			Data:       map[string]string{cosignPublicKeyKey: string(publicKey)},
+		},
+	).Build()
+
+	block, _ := pem.Decode(publicKey)
+
+	tests := []struct {
+		name     string
+		path     string
+		signedBy bool
+	}{
+		{name: "signed", path: "/", signedBy: true},
+		{name: "signed by another key", path: "/other/"},
+		{name: "no signature", path: "/unsigned/"},
+	}
+
+	for _, tt := range tests {
+		dir, err := ioutil.TempDir("/tmp", "charts")
+		assert.NoError(t, err)
+
+		defer os.RemoveAll(dir)
+
+		chart, err := FetchChartWithClient(c, nil, nil, dir, &appv1.HelmRelease{
+			ObjectMeta: metav1.ObjectMeta{Name: "subscription-release-test-1-cr", Namespace: "default"},
+			Repo: appv1.HelmReleaseRepo{
+				Source: &appv1.Source{
+					SourceType: appv1.HelmRepoSourceType,
+					HelmRepo: &appv1.HelmRepo{
+						Urls: []string{server.URL + tt.path + "subscription-release-test-1-0.1.0.tgz"},
+						Cosign: &appv1.CosignVerification{
+							ConfigMapRef: &corev1.ObjectReference{Name: "cosign"},
+						},
+					},
+				},
+				ChartName: "subscription-release-test-1",
+			},
+		})
+
+		if !tt.signedBy {
+			assert.True(t, errors.Is(err, ErrChartNotVerified), tt.name)
+			continue
+		}
+
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, "sha256:"+hexSHA256(block.Bytes), chart.SignedBy, tt.name)
+		}
+	}
+}
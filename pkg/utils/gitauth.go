@@ -0,0 +1,303 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+const (
+	// the keys of the secret of the GitHub App credentials
+	githubAppIDKey             = "githubAppID"
+	githubAppInstallationIDKey = "githubAppInstallationID"
+	githubAppPrivateKeyKey     = "githubAppPrivateKey"
+	githubAppAPIURLKey         = "githubAppAPIURL"
+
+	// the key of the secret of the bearer token
+	bearerTokenKey = "bearerToken"
+	// the key of the config map of the path of the token file
+	tokenFileKey = "tokenFile"
+
+	defaultGitHubAPIURL = "https://api.github.com"
+
+	// the installation tokens are minted again when they expire in less than githubAppTokenRenewal
+	githubAppTokenRenewal = 5 * time.Minute
+)
+
+// githubAppToken is an installation token and its expiration
+type githubAppToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+var (
+	githubAppTokensMu sync.Mutex
+	githubAppTokens   = map[string]githubAppToken{}
+)
+
+// gitHTTPAuth returns the authentication of a git HTTP url, in order of precedence: a GitHub App installation
+// token, the credential of the url in the repositories.yaml or .dockerconfigjson of the secret, a bearer token,
+// the token of the token file of the config map, the user and accessToken of the secret
+func gitHTTPAuth(configMap *corev1.ConfigMap, secret *corev1.Secret, url string,
+	insecureSkipVerify bool) (transport.AuthMethod, error) {
+	if secret != nil && len(secret.Data[githubAppIDKey]) > 0 {
+		token, err := githubAppInstallationToken(configMap, secret, insecureSkipVerify, time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		// GitHub takes the installation tokens as the password of the x-access-token user
+		return &githttp.BasicAuth{Username: "x-access-token", Password: token}, nil
+	}
+
+	if hasRepoCredentials(secret) {
+		credential, err := repoCredentialFor(secret, url)
+		if err != nil || credential == nil {
+			return nil, err
+		}
+
+		klog.V(5).Info("Add credentials of ", credential.URL)
+
+		if credential.BearerToken != "" {
+			return &githttp.TokenAuth{Token: credential.BearerToken}, nil
+		}
+
+		return &githttp.BasicAuth{Username: credential.Username, Password: credential.Password}, nil
+	}
+
+	if secret != nil && len(secret.Data[bearerTokenKey]) > 0 {
+		klog.V(5).Info("Add bearer token")
+
+		return &githttp.TokenAuth{Token: strings.TrimSpace(string(secret.Data[bearerTokenKey]))}, nil
+	}
+
+	if configMap != nil && configMap.Data[tokenFileKey] != "" {
+		tokenFile, err := tokenFilePath(configMap.Data[tokenFileKey])
+		if err != nil {
+			return nil, err
+		}
+
+		// the token file is read on every fetch, it can be a projected token that is rotated
+		token, err := ioutil.ReadFile(filepath.Clean(tokenFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the token file %s: %w", configMap.Data[tokenFileKey], err)
+		}
+
+		klog.V(5).Info("Add token of file ", configMap.Data[tokenFileKey])
+
+		return &githttp.TokenAuth{Token: strings.TrimSpace(string(token))}, nil
+	}
+
+	if secret != nil && secret.Data != nil {
+		klog.V(5).Info("Add credentials")
+
+		return &githttp.BasicAuth{
+			Username: string(secret.Data["user"]),
+			Password: GetAccessToken(secret),
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// tokenFilePath returns the path of the token file of the config map. The config maps are written by the
+// tenants, so they can only name a file of the token files directory of the operator, never another file of
+// the operator pod such as its service account token.
+func tokenFilePath(name string) (string, error) {
+	dir := os.Getenv(appv1.TokenFilesDir)
+	if dir == "" {
+		return "", fmt.Errorf("the %s key requires the operator to set the token files directory", tokenFileKey)
+	}
+
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("the %s %s is not a file name of the token files directory", tokenFileKey, name)
+	}
+
+	return filepath.Join(dir, name), nil
+}
+
+// githubAppInstallationToken returns an installation token of the GitHub App of the secret. The tokens are
+// cached until they are about to expire.
+func githubAppInstallationToken(configMap *corev1.ConfigMap, secret *corev1.Secret, insecureSkipVerify bool,
+	now time.Time) (string, error) {
+	appID := strings.TrimSpace(string(secret.Data[githubAppIDKey]))
+	installationID := strings.TrimSpace(string(secret.Data[githubAppInstallationIDKey]))
+
+	apiURL := strings.TrimSuffix(strings.TrimSpace(string(secret.Data[githubAppAPIURLKey])), "/")
+	if apiURL == "" {
+		apiURL = defaultGitHubAPIURL
+	}
+
+	if installationID == "" {
+		return "", fmt.Errorf("the secret of GitHub App %s has no %s", appID, githubAppInstallationIDKey)
+	}
+
+	privateKeyPEM := secret.Data[githubAppPrivateKeyKey]
+
+	privateKey, err := parseRSAPrivateKey(privateKeyPEM)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the private key of GitHub App %s: %w", appID, err)
+	}
+
+	// the tokens are cached by private key, only the holders of the key of the app get its cached tokens
+	keyHash := sha256.Sum256(privateKeyPEM)
+	cacheKey := apiURL + "|" + appID + "|" + installationID + "|" + hex.EncodeToString(keyHash[:])
+
+	githubAppTokensMu.Lock()
+	cached, ok := githubAppTokens[cacheKey]
+	githubAppTokensMu.Unlock()
+
+	if ok && cached.ExpiresAt.Sub(now) > githubAppTokenRenewal {
+		return cached.Token, nil
+	}
+
+	jwt, err := githubAppJWT(appID, privateKey, now)
+	if err != nil {
+		return "", err
+	}
+
+	httpClient, err := newSourceHTTPClient(configMap, secret, apiURL, insecureSkipVerify)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, apiURL+"/app/installations/"+installationID+"/access_tokens", nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get an installation token of GitHub App %s: %w", appID, err)
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("return code: %d unable to get an installation token of GitHub App %s: %s",
+			resp.StatusCode, appID, bytes.TrimSpace(body))
+	}
+
+	token := githubAppToken{}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("failed to parse the installation token of GitHub App %s: %w", appID, err)
+	}
+
+	if token.Token == "" {
+		return "", fmt.Errorf("no installation token for GitHub App %s", appID)
+	}
+
+	klog.V(3).Info("Got an installation token of GitHub App ", appID, " expiring at ", token.ExpiresAt)
+
+	githubAppTokensMu.Lock()
+	defer githubAppTokensMu.Unlock()
+
+	for key, cached := range githubAppTokens {
+		if !cached.ExpiresAt.After(now) {
+			delete(githubAppTokens, key)
+		}
+	}
+
+	githubAppTokens[cacheKey] = token
+
+	return token.Token, nil
+}
+
+// githubAppJWT returns the RS256 JSON Web Token that authenticates the GitHub App
+func githubAppJWT(appID string, privateKey *rsa.PrivateKey, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	// issued in the past to allow for clock drift, GitHub accepts tokens valid for up to 10 minutes
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": appID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	hashed := sha256.Sum256([]byte(unsigned))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parseRSAPrivateKey parses a PKCS #1 or PKCS #8 PEM encoded RSA private key
+func parseRSAPrivateKey(privateKeyPEM []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(bytes.TrimSpace(privateKeyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM private key")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("the private key is not an RSA key")
+	}
+
+	return rsaKey, nil
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	corev1 "k8s.io/api/core/v1"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// newGitHubTokenEndpoint serves installation tokens for the requests authenticated with a JWT of app 42
+// signed by the key
+func newGitHubTokenEndpoint(key *rsa.PrivateKey, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+
+		if r.Method != http.MethodPost || r.URL.Path != "/app/installations/7/access_tokens" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		parts := strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), ".")
+		if len(parts) != 3 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+		if rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hashed[:], signature) != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		claims := map[string]interface{}{}
+		b, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		_ = json.Unmarshal(b, &claims)
+
+		if claims["iss"] != "42" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token":"ghs_installation%d","expires_at":"%s"}`, *requests,
+			time.Now().Add(time.Hour).Format(time.RFC3339))
+	}))
+}
+
+func TestGitHTTPAuthGitHubApp(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	requests := 0
+	server := newGitHubTokenEndpoint(key, &requests)
+
+	defer server.Close()
+
+	secret := &corev1.Secret{
+		Data: map[string][]byte{
+			githubAppIDKey:             []byte("42"),
+			githubAppInstallationIDKey: []byte("7"),
+			githubAppPrivateKeyKey:     keyPEM,
+			githubAppAPIURLKey:         []byte(server.URL),
+		},
+	}
+
+	auth, err := gitHTTPAuth(nil, secret, "https://github.com/org/repo.git", false)
+	assert.NoError(t, err)
+	assert.Equal(t, &githttp.BasicAuth{Username: "x-access-token", Password: "ghs_installation1"}, auth)
+
+	// the token is cached until it is about to expire
+	auth, err = gitHTTPAuth(nil, secret, "https://github.com/org/repo.git", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "ghs_installation1", auth.(*githttp.BasicAuth).Password)
+	assert.Equal(t, 1, requests)
+
+	_, err = githubAppInstallationToken(nil, secret, false, time.Now().Add(56*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, requests)
+
+	// the cached token is not given to a secret of the same app and installation with another private key, or
+	// with no private key
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	secret.Data[githubAppPrivateKeyKey] = pem.EncodeToMemory(&pem.Block{
+		Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(otherKey),
+	})
+	_, err = gitHTTPAuth(nil, secret, "https://github.com/org/repo.git", false)
+	assert.Error(t, err)
+	assert.Equal(t, 3, requests)
+
+	delete(secret.Data, githubAppPrivateKeyKey)
+	_, err = gitHTTPAuth(nil, secret, "https://github.com/org/repo.git", false)
+	assert.Error(t, err)
+	assert.Equal(t, 3, requests)
+
+	// an app the endpoint does not know
+	secret.Data[githubAppPrivateKeyKey] = keyPEM
+	secret.Data[githubAppIDKey] = []byte("43")
+	_, err = gitHTTPAuth(nil, secret, "https://github.com/org/repo.git", false)
+	assert.Error(t, err)
+}
+
+func TestGitHTTPAuthTokens(t *testing.T) {
+	auth, err := gitHTTPAuth(nil, &corev1.Secret{Data: map[string][]byte{bearerTokenKey: []byte("token\n")}},
+		"https://example.com/repo.git", false)
+	assert.NoError(t, err)
+	assert.Equal(t, &githttp.TokenAuth{Token: "token"}, auth)
+
+	tokenFilesDir, err := ioutil.TempDir("/tmp", "tokens")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(tokenFilesDir)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(tokenFilesDir, "token"), []byte("projected"), 0600))
+
+	configMap := &corev1.ConfigMap{Data: map[string]string{tokenFileKey: "token"}}
+
+	// the token files are disabled unless the operator sets their directory
+	_, err = gitHTTPAuth(configMap, nil, "https://example.com/repo.git", false)
+	assert.Error(t, err)
+
+	os.Setenv(appv1.TokenFilesDir, tokenFilesDir)
+	defer os.Unsetenv(appv1.TokenFilesDir)
+
+	auth, err = gitHTTPAuth(configMap, nil, "https://example.com/repo.git", false)
+	assert.NoError(t, err)
+	assert.Equal(t, &githttp.TokenAuth{Token: "projected"}, auth)
+
+	// the config map can not name a file outside of the directory
+	for _, name := range []string{"/var/run/secrets/kubernetes.io/serviceaccount/token", "../token", "sub/token", ".."} {
+		_, err = gitHTTPAuth(&corev1.ConfigMap{Data: map[string]string{tokenFileKey: name}}, nil,
+			"https://example.com/repo.git", false)
+		assert.Error(t, err, name)
+	}
+
+	auth, err = gitHTTPAuth(nil, &corev1.Secret{Data: map[string][]byte{"user": []byte("u"), "accessToken": []byte("p")}},
+		"https://example.com/repo.git", false)
+	assert.NoError(t, err)
+	assert.Equal(t, &githttp.BasicAuth{Username: "u", Password: "p"}, auth)
+
+	auth, err = gitHTTPAuth(nil, nil, "https://example.com/repo.git", false)
+	assert.NoError(t, err)
+	assert.Nil(t, auth)
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"k8s.io/klog"
+)
+
+// errGitSubmodules is returned when the chart path of a cached repository has submodules to check out
+var errGitSubmodules = errors.New("the chart path has submodules")
+
+// errGitCacheCorrupted is returned when the cached repository can not be read, it is then fetched again
+var errGitCacheCorrupted = errors.New("the cached repository is corrupted")
+
+var (
+	gitCacheLocksMu sync.Mutex
+	gitCacheLocks   = map[string]*sync.Mutex{}
+)
+
+// gitCacheLock returns the lock of a cached repository, the HelmReleases of the same repository
+// fetch and read it one at a time
+func gitCacheLock(dir string) *sync.Mutex {
+	gitCacheLocksMu.Lock()
+	defer gitCacheLocksMu.Unlock()
+
+	lock, ok := gitCacheLocks[dir]
+	if !ok {
+		lock = &sync.Mutex{}
+		gitCacheLocks[dir] = lock
+	}
+
+	return lock
+}
+
+// gitCacheDir returns the directory of the bare repository caching a git url
+func gitCacheDir(cacheDir, url string) string {
+	sum := sha256.Sum256([]byte(url))
+
+	return filepath.Join(cacheDir, "git", hex.EncodeToString(sum[:16]))
+}
+
+// checkoutFromGitCache fetches the branch of the clone options into the bare repository cached in cacheDir,
+// then writes the files of chartPath at the fetched commit into destDir/chartPath. Only the objects of the
+// tip of the branch are fetched, and only the new ones once the repository is cached. The HTTP urls are fetched
+// with the transport, see withGitTransport.
+func checkoutFromGitCache(options *git.CloneOptions, transport http.RoundTripper, cacheDir, destDir, chartPath string,
+	submodules bool) (commitID string, err error) {
+	repoDir := gitCacheDir(cacheDir, options.URL)
+
+	lock := gitCacheLock(repoDir)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// the cache is shared by the HelmReleases of the repository, it is only dropped when it is corrupted, not
+	// when the credentials of a HelmRelease or the network fail
+	r, hash, err := fetchToGitCache(options, transport, repoDir)
+	if errors.Is(err, errGitCacheCorrupted) {
+		klog.Warning("Failed to read the cache of ", options.URL, ", fetching it again: ", err)
+
+		if rErr := os.RemoveAll(repoDir); rErr != nil {
+			klog.Error(rErr, "- Failed to remove all: ", repoDir)
+		}
+
+		r, hash, err = fetchToGitCache(options, transport, repoDir)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	commit, err := r.CommitObject(hash)
+	if err != nil {
+		return "", err
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return "", err
+	}
+
+	chartPath = strings.Trim(path.Clean("/"+filepath.ToSlash(chartPath)), "/")
+	if chartPath != "" {
+		tree, err = tree.Tree(chartPath)
+		if err != nil {
+			return "", fmt.Errorf("chart path %s not found at commit %s: %w", chartPath, hash, err)
+		}
+	}
+
+	if submodules && hasSubmodules(tree) {
+		return "", errGitSubmodules
+	}
+
+	if err := writeGitTree(tree, filepath.Join(destDir, filepath.FromSlash(chartPath))); err != nil {
+		return "", err
+	}
+
+	klog.V(5).Info("commitID: ", hash.String())
+
+	return hash.String(), nil
+}
+
+// fetchToGitCache fetches the branch into the bare repository, creating it if needed, and returns the
+// commit of the branch
+func fetchToGitCache(options *git.CloneOptions, transport http.RoundTripper,
+	repoDir string) (*git.Repository, plumbing.Hash, error) {
+	r, err := git.PlainOpen(repoDir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		r, err = git.PlainInit(repoDir, true)
+		if err == nil {
+			_, err = r.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{options.URL}})
+		}
+	} else if err == nil {
+		// the configuration is read by the fetch, an error then would not tell a corrupted cache from a
+		// failed fetch
+		_, err = r.Remote(git.DefaultRemoteName)
+	}
+
+	if err != nil {
+		return nil, plumbing.ZeroHash, fmt.Errorf("%w: %v", errGitCacheCorrupted, err)
+	}
+
+	refSpec := config.RefSpec("+" + options.ReferenceName.String() + ":" + options.ReferenceName.String())
+
+	err = withGitTransport(options.URL, transport, func() error {
+		return r.Fetch(&git.FetchOptions{
+			RemoteName: git.DefaultRemoteName,
+			RefSpecs:   []config.RefSpec{refSpec},
+			Depth:      1,
+			Auth:       options.Auth,
+			Tags:       git.NoTags,
+			Force:      true,
+		})
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil, plumbing.ZeroHash, err
+	}
+
+	ref, err := r.Reference(options.ReferenceName, true)
+	if err != nil {
+		return nil, plumbing.ZeroHash, fmt.Errorf("%w: %v", errGitCacheCorrupted, err)
+	}
+
+	return r, ref.Hash(), nil
+}
+
+// hasSubmodules returns true if the tree has submodules
+func hasSubmodules(tree *object.Tree) bool {
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+
+	for {
+		_, entry, err := walker.Next()
+		if err != nil {
+			return false
+		}
+
+		if entry.Mode == filemode.Submodule {
+			return true
+		}
+	}
+}
+
+// writeGitTree writes the files of the tree in dir. The symbolic links are skipped.
+func writeGitTree(tree *object.Tree, dir string) error {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+
+	return tree.Files().ForEach(func(f *object.File) error {
+		if f.Mode == filemode.Symlink {
+			klog.Warning("Skipping the symbolic link ", f.Name)
+			return nil
+		}
+
+		dest := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if !strings.HasPrefix(dest, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid file name %s", f.Name)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(dest), 0750); err != nil {
+			return err
+		}
+
+		perm := os.FileMode(0640)
+		if f.Mode == filemode.Executable {
+			perm = 0750
+		}
+
+		reader, err := f.Reader()
+		if err != nil {
+			return err
+		}
+
+		defer closeHelper(reader)
+
+		out, err := os.OpenFile(filepath.Clean(dest), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+		if err != nil {
+			return err
+		}
+
+		defer closeHelper(out)
+
+		_, err = io.Copy(out, reader)
+
+		return err
+	})
+}
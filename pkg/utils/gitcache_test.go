@@ -0,0 +1,169 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// commitFiles writes the files in the worktree of the repository and commits them
+func commitFiles(t *testing.T, r *git.Repository, dir string, files map[string]string) string {
+	w, err := r.Worktree()
+	assert.NoError(t, err)
+
+	for name, content := range files {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0750))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+
+		_, err = w.Add(name)
+		assert.NoError(t, err)
+	}
+
+	hash, err := w.Commit("update", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	assert.NoError(t, err)
+
+	return hash.String()
+}
+
+func TestFetchChartGitCache(t *testing.T) {
+	repoDir, err := ioutil.TempDir("/tmp", "gitrepo")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(repoDir)
+
+	r, err := git.PlainInit(repoDir, false)
+	assert.NoError(t, err)
+
+	commitFiles(t, r, repoDir, map[string]string{
+		"charts/test/Chart.yaml":               "apiVersion: v1\nname: test\nversion: 0.1.0\n",
+		"charts/test/templates/configmap.yaml": "apiVersion: v1\nkind: ConfigMap\n",
+		"charts/other/Chart.yaml":              "apiVersion: v1\nname: other\nversion: 0.1.0\n",
+		"README.md":                            "monorepo\n",
+	})
+
+	chartsDir, err := ioutil.TempDir("/tmp", "charts")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(chartsDir)
+
+	hr := &appv1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "git-cache", Namespace: "default"},
+		Repo: appv1.HelmReleaseRepo{
+			Source: &appv1.Source{
+				SourceType: appv1.GitSourceType,
+				Git: &appv1.Git{
+					Urls:      []string{"file://" + repoDir},
+					ChartPath: "charts/test",
+					Branch:    "master",
+				},
+			},
+			ChartName: "test",
+		},
+	}
+
+	chart, err := FetchChart(nil, nil, chartsDir, hr)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = os.Stat(filepath.Join(chart.Dir, "templates", "configmap.yaml"))
+	assert.NoError(t, err)
+
+	// only the chart path is checked out
+	_, err = os.Stat(filepath.Join(chart.Dir, "..", "other"))
+	assert.True(t, os.IsNotExist(err))
+
+	// the next fetch gets the new commit from the cache
+	commitID := commitFiles(t, r, repoDir, map[string]string{"charts/test/values.yaml": "replicas: 1\n"})
+
+	chart, err = FetchChart(nil, nil, chartsDir, hr)
+	assert.NoError(t, err)
+	assert.Equal(t, commitID, chart.Revision)
+
+	_, err = os.Stat(filepath.Join(chart.Dir, "values.yaml"))
+	assert.NoError(t, err)
+
+	_, err = os.Stat(gitCacheDir(filepath.Join(chartsDir, ".cache"), "file://"+repoDir))
+	assert.NoError(t, err)
+
+	hr.Repo.Source.Git.ChartPath = "charts/missing"
+	_, err = FetchChart(nil, nil, chartsDir, hr)
+	assert.Error(t, err)
+}
+
+func TestGitCacheFailedFetch(t *testing.T) {
+	repoDir, err := ioutil.TempDir("/tmp", "gitrepo")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(repoDir)
+
+	r, err := git.PlainInit(repoDir, false)
+	assert.NoError(t, err)
+
+	commitID := commitFiles(t, r, repoDir, map[string]string{
+		"charts/test/Chart.yaml": "apiVersion: v1\nname: test\nversion: 0.1.0\n",
+	})
+
+	chartsDir, err := ioutil.TempDir("/tmp", "charts")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(chartsDir)
+
+	options := &git.CloneOptions{URL: "file://" + repoDir, ReferenceName: "refs/heads/master"}
+	cacheDir := filepath.Join(chartsDir, ".cache")
+	repoCacheDir := gitCacheDir(cacheDir, options.URL)
+
+	_, err = checkoutFromGitCache(options, nil, cacheDir, filepath.Join(chartsDir, "1"), "charts/test", false)
+	assert.NoError(t, err)
+
+	// a failed fetch, like a bad token or an unreachable server, keeps the cache of the other HelmReleases
+	assert.NoError(t, os.Rename(repoDir, repoDir+".moved"))
+
+	_, err = checkoutFromGitCache(options, nil, cacheDir, filepath.Join(chartsDir, "2"), "charts/test", false)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, errGitCacheCorrupted))
+
+	assert.NoError(t, os.Rename(repoDir+".moved", repoDir))
+
+	cached, err := git.PlainOpen(repoCacheDir)
+	if assert.NoError(t, err) {
+		ref, err := cached.Reference("refs/heads/master", true)
+		assert.NoError(t, err)
+		assert.Equal(t, commitID, ref.Hash().String())
+	}
+
+	// a corrupted cache is fetched again
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(repoCacheDir, "config"), []byte("[remote"), 0600))
+
+	revision, err := checkoutFromGitCache(options, nil, cacheDir, filepath.Join(chartsDir, "3"), "charts/test", false)
+	assert.NoError(t, err)
+	assert.Equal(t, commitID, revision)
+}
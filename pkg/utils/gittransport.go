@@ -0,0 +1,111 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+
+	gitclient "gopkg.in/src-d/go-git.v4/plumbing/transport/client"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+)
+
+// gitHostTransports routes the git HTTP requests to the transport of their scheme and host. go-git resolves
+// the transport of a protocol for the whole process while the TLS settings, such as the client certificates,
+// and the proxies belong to the sources. The transport of a host is only set for the duration of a clone or
+// fetch, under the lock of the host, so the clones of the same host by several HelmReleases never use the
+// transport of another one.
+type gitHostTransports struct {
+	mu    sync.RWMutex
+	hosts map[string]http.RoundTripper
+	locks map[string]*sync.Mutex
+}
+
+var (
+	gitTransports = &gitHostTransports{
+		hosts: map[string]http.RoundTripper{},
+		locks: map[string]*sync.Mutex{},
+	}
+	installGitTransportOnce sync.Once
+)
+
+// withGitTransport runs the clone or fetch of the url with the transport, nil for the default transport. The
+// clones of the same scheme://host are serialized.
+func withGitTransport(gitURL string, transport http.RoundTripper, clone func() error) error {
+	u, err := url.Parse(gitURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return clone()
+	}
+
+	installGitTransportOnce.Do(func() {
+		client := githttp.NewClient(&http.Client{Transport: gitTransports})
+
+		gitclient.InstallProtocol("http", client)
+		gitclient.InstallProtocol("https", client)
+	})
+
+	host := u.Scheme + "://" + u.Host
+
+	lock := gitTransports.lock(host)
+	lock.Lock()
+	defer lock.Unlock()
+
+	gitTransports.set(host, transport)
+	defer gitTransports.set(host, nil)
+
+	return clone()
+}
+
+// lock returns the lock of the clones of the host
+func (t *gitHostTransports) lock(host string) *sync.Mutex {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lock, ok := t.locks[host]
+	if !ok {
+		lock = &sync.Mutex{}
+		t.locks[host] = lock
+	}
+
+	return lock
+}
+
+// set sets the transport of the git requests to a scheme://host, nil restores the default transport
+func (t *gitHostTransports) set(host string, transport http.RoundTripper) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if transport == nil {
+		delete(t.hosts, host)
+		return
+	}
+
+	t.hosts[host] = transport
+}
+
+func (t *gitHostTransports) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.RLock()
+	transport, ok := t.hosts[req.URL.Scheme+"://"+req.URL.Host]
+	t.mu.RUnlock()
+
+	if !ok {
+		transport = http.DefaultTransport
+	}
+
+	return transport.RoundTrip(req)
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithGitTransport(t *testing.T) {
+	transport := &http.Transport{}
+
+	err := withGitTransport("https://git.example.com/org/repo.git", transport, func() error {
+		assert.Equal(t, transport, gitTransports.hosts["https://git.example.com"])
+		return errors.New("clone failed")
+	})
+	assert.EqualError(t, err, "clone failed")
+
+	// the transport is only set for the duration of the clone
+	assert.NotContains(t, gitTransports.hosts, "https://git.example.com")
+
+	// the clones of the same host never see the transport of another clone
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			own := &http.Transport{}
+
+			assert.NoError(t, withGitTransport("https://git.example.com/org/repo.git", own, func() error {
+				gitTransports.mu.RLock()
+				defer gitTransports.mu.RUnlock()
+
+				assert.Same(t, own, gitTransports.hosts["https://git.example.com"])
+
+				return nil
+			}))
+		}()
+	}
+
+	wg.Wait()
+
+	// the SSH urls are cloned without the transports
+	called := false
+	assert.NoError(t, withGitTransport("git@github.com:org/repo.git", transport, func() error {
+		called = true
+		return nil
+	}))
+	assert.True(t, called)
+}
@@ -18,32 +18,27 @@ package utils
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/pem"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strconv"
 	"strings"
-	"time"
 
 	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/crypto/ssh/knownhosts"
 	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing"
-	gitclient "gopkg.in/src-d/go-git.v4/plumbing/transport/client"
-	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
 	"helm.sh/helm/v3/pkg/chartutil"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog"
 
@@ -52,111 +47,27 @@ import (
 
 //GetHelmRepoClient returns an *http.client to access the helm repo
 func GetHelmRepoClient(parentNamespace string, configMap *corev1.ConfigMap, skipCertVerify bool) (rest.HTTPClient, error) {
-	/* #nosec G402 */
-	transport := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-			DualStack: true,
-		}).DialContext,
-		MaxIdleConns:          100,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: skipCertVerify, // #nosec G402 InsecureSkipVerify conditionally
-			MinVersion:         tls.VersionTLS12,
-		},
-	}
-
 	if skipCertVerify {
 		klog.Info("repo.insecureSkipVerify=true. Skipping repo server's certificate verification.")
 	}
 
-	if configMap != nil {
-		configData := configMap.Data
-		klog.V(5).Info("ConfigRef retrieved :", configData)
-		insecureSkipVerify := configData["insecureSkipVerify"]
-
-		if insecureSkipVerify != "" {
-			b, err := strconv.ParseBool(insecureSkipVerify)
-			if err != nil {
-				if errors.IsNotFound(err) {
-					return nil, nil
-				}
-
-				klog.Error(err, " - Unable to parse insecureSkipVerify", insecureSkipVerify)
-
-				return nil, err
-			}
-
-			klog.Info("From config map, seting InsecureSkipVerify: ", b)
-			transport.TLSClientConfig.InsecureSkipVerify = b
-		} else {
-			klog.V(5).Info("insecureSkipVerify is not specified")
-		}
-	} else {
-		klog.V(5).Info("configMap is nil")
-	}
-
-	httpClient := http.DefaultClient
-	httpClient.Transport = transport
-	klog.V(5).Info("InsecureSkipVerify equal ", transport.TLSClientConfig.InsecureSkipVerify)
-
-	return httpClient, nil
-}
-
-//DownloadChart downloads the charts
-func DownloadChart(configMap *corev1.ConfigMap,
-	secret *corev1.Secret,
-	chartsDir string,
-	s *appv1.HelmRelease) (chartDir string, err error) {
-	destRepo := filepath.Join(chartsDir, s.Name, s.Namespace, s.Repo.ChartName)
-	if _, err := os.Stat(destRepo); os.IsNotExist(err) {
-		err := os.MkdirAll(destRepo, 0750)
-		if err != nil {
-			klog.Error(err, " - Unable to create chartDir: ", destRepo)
-			return "", err
-		}
-	}
-
-	switch strings.ToLower(string(s.Repo.Source.SourceType)) {
-	case string(appv1.HelmRepoSourceType):
-		return DownloadChartFromHelmRepo(configMap, secret, destRepo, s)
-	case string(appv1.GitHubSourceType):
-		return DownloadChartFromGit(configMap, secret, destRepo, s)
-	case string(appv1.GitSourceType):
-		return DownloadChartFromGit(configMap, secret, destRepo, s)
-	default:
-		return "", fmt.Errorf("sourceType '%s' unsupported", s.Repo.Source.SourceType)
-	}
+	return newSourceHTTPClient(configMap, nil, "", skipCertVerify)
 }
 
 //DownloadChartFromGit downloads a chart into the charsDir
 func DownloadChartFromGit(configMap *corev1.ConfigMap, secret *corev1.Secret, destRepo string, s *appv1.HelmRelease) (chartDir string, err error) {
-	if s.Repo.Source.GitHub == nil && s.Repo.Source.Git == nil {
-		err := fmt.Errorf("git type, need Repo.Source.Git or Repo.Source.GitHub to be populated.")
-		return "", err
-	}
-
-	if s.Repo.Source.GitHub != nil {
-		_, err = DownloadGitRepo(configMap, secret, destRepo, s.Repo.Source.GitHub.Urls, s.Repo.Source.GitHub.Branch, s.Repo.InsecureSkipVerify)
-	} else if s.Repo.Source.Git != nil {
-		_, err = DownloadGitRepo(configMap, secret, destRepo, s.Repo.Source.Git.Urls, s.Repo.Source.Git.Branch, s.Repo.InsecureSkipVerify)
-	}
-
+	chart, err := fetchChartFrom(gitChartSource{}, &ChartRequest{
+		HelmRelease: s,
+		Source:      s.Repo.Source,
+		ConfigMap:   configMap,
+		Secret:      secret,
+		DestDir:     destRepo,
+	})
 	if err != nil {
 		return "", err
 	}
 
-	if s.Repo.Source.GitHub != nil {
-		chartDir = filepath.Join(destRepo, s.Repo.Source.GitHub.ChartPath)
-	} else if s.Repo.Source.Git != nil {
-		chartDir = filepath.Join(destRepo, s.Repo.Source.Git.ChartPath)
-	}
-
-	return chartDir, nil
+	return chart.Dir, nil
 }
 
 //DownloadGitRepo downloads a git repo into the charsDir
@@ -165,29 +76,16 @@ func DownloadGitRepo(configMap *corev1.ConfigMap,
 	destRepo string,
 	urls []string, branch string,
 	insecureSkipVerify bool) (commitID string, err error) {
-	for _, url := range urls {
-		options := &git.CloneOptions{
-			URL:               url,
-			Depth:             1,
-			SingleBranch:      true,
-			RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
-		}
-
-		if secret != nil && secret.Data != nil {
-			klog.V(5).Info("Add credentials")
-
-			options.Auth = &githttp.BasicAuth{
-				Username: string(secret.Data["user"]),
-				Password: GetAccessToken(secret),
-			}
-		}
-
-		if branch == "" {
-			options.ReferenceName = plumbing.Master
-		} else {
-			options.ReferenceName = plumbing.ReferenceName("refs/heads/" + branch)
-		}
+	return downloadGitRepo(configMap, secret, destRepo, urls, branch, insecureSkipVerify, true)
+}
 
+func downloadGitRepo(configMap *corev1.ConfigMap,
+	secret *corev1.Secret,
+	destRepo string,
+	urls []string, branch string,
+	insecureSkipVerify bool,
+	submodules bool) (commitID string, err error) {
+	for _, url := range urls {
 		rErr := os.RemoveAll(destRepo)
 		if rErr != nil {
 			klog.Error(err, "- Failed to remove all: ", destRepo)
@@ -198,50 +96,23 @@ func DownloadGitRepo(configMap *corev1.ConfigMap,
 			return "", err
 		}
 
-		if strings.HasPrefix(url, "http") {
-			klog.Info("Connecting to Git server via HTTP")
-
-			caCert := ""
-
-			if configMap != nil {
-				caCert = configMap.Data["caCerts"]
-			}
-
-			err := getHTTPOptions(options, caCert, insecureSkipVerify)
-
-			if err != nil {
-				klog.Error(err, "failed to prepare HTTP clone options")
-				return "", err
-			}
-		} else {
-			klog.Info("Connecting to Git server via SSH")
-
-			knownhostsfile := filepath.Join(destRepo, "known_hosts")
-
-			if !insecureSkipVerify {
-				err := getKnownHostFromURL(url, knownhostsfile)
-
-				if err != nil {
-					return "", err
-				}
-			}
+		options, transport, errOptions := gitCloneOptions(configMap, secret, destRepo, url, branch, insecureSkipVerify)
+		if errOptions != nil {
+			return "", errOptions
+		}
 
-			sshKey := []byte("")
-			passphrase := []byte("")
+		if !submodules {
+			options.RecurseSubmodules = git.NoRecurseSubmodules
+		}
 
-			if secret != nil {
-				sshKey = bytes.TrimSpace(secret.Data["sshKey"])
-				passphrase = bytes.TrimSpace(secret.Data["passphrase"])
-			}
+		var r *git.Repository
 
-			err = getSSHOptions(options, sshKey, passphrase, knownhostsfile, insecureSkipVerify)
-			if err != nil {
-				klog.Error(err, " failed to prepare SSH clone options")
-				return "", err
-			}
-		}
+		errClone := withGitTransport(url, transport, func() error {
+			var errPlainClone error
+			r, errPlainClone = git.PlainClone(destRepo, false, options)
 
-		r, errClone := git.PlainClone(destRepo, false, options)
+			return errPlainClone
+		})
 
 		if errClone != nil {
 			rErr = os.RemoveAll(destRepo)
@@ -280,6 +151,85 @@ func DownloadGitRepo(configMap *corev1.ConfigMap,
 	return commitID, err
 }
 
+// gitCloneOptions returns the options to clone the branch of a git repo, and the transport of the HTTP urls
+// to clone it with, see withGitTransport. The known_hosts of the SSH urls is written in dir.
+func gitCloneOptions(configMap *corev1.ConfigMap,
+	secret *corev1.Secret,
+	dir, url, branch string,
+	insecureSkipVerify bool) (*git.CloneOptions, http.RoundTripper, error) {
+	options := &git.CloneOptions{
+		URL:               url,
+		Depth:             1,
+		SingleBranch:      true,
+		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+	}
+
+	if branch == "" {
+		options.ReferenceName = plumbing.Master
+	} else {
+		options.ReferenceName = plumbing.ReferenceName("refs/heads/" + branch)
+	}
+
+	var transport http.RoundTripper
+
+	switch {
+	case strings.HasPrefix(url, "file://"):
+		klog.Info("Reading local Git repo")
+	case strings.HasPrefix(url, "http"):
+		klog.Info("Connecting to Git server via HTTP")
+
+		auth, err := gitHTTPAuth(configMap, secret, url, insecureSkipVerify)
+		if err != nil {
+			klog.Error(err, " failed to prepare HTTP clone credentials")
+			return nil, nil, err
+		}
+
+		options.Auth = auth
+
+		settings, err := sourceTLSFor(configMap, secret, url, insecureSkipVerify)
+		if err != nil {
+			klog.Error(err, " failed to prepare HTTP clone TLS settings")
+			return nil, nil, err
+		}
+
+		proxy, err := sourceProxy(configMap, secret)
+		if err != nil {
+			klog.Error(err, " failed to prepare HTTP clone proxy")
+			return nil, nil, err
+		}
+
+		transport, err = getHTTPTransport(options.URL, settings, proxy)
+		if err != nil {
+			klog.Error(err, "failed to prepare HTTP clone options")
+			return nil, nil, err
+		}
+	default:
+		klog.Info("Connecting to Git server via SSH")
+
+		hostKeyCallback, err := sshHostKeyCallback(configMap, secret, url, dir, insecureSkipVerify)
+		if err != nil {
+			klog.Error(err, " failed to prepare SSH host key verification")
+			return nil, nil, err
+		}
+
+		sshKey := []byte("")
+		passphrase := []byte("")
+
+		if secret != nil {
+			sshKey = bytes.TrimSpace(secret.Data["sshKey"])
+			passphrase = bytes.TrimSpace(secret.Data["passphrase"])
+		}
+
+		err = getSSHOptions(options, sshKey, passphrase, hostKeyCallback)
+		if err != nil {
+			klog.Error(err, " failed to prepare SSH clone options")
+			return nil, nil, err
+		}
+	}
+
+	return options, transport, nil
+}
+
 func getCertChain(certs string) tls.Certificate {
 	var certChain tls.Certificate
 
@@ -302,6 +252,34 @@ func getCertChain(certs string) tls.Certificate {
 	return certChain
 }
 
+// newCertPool returns the host's trusted certs plus the PEM encoded caCerts
+func newCertPool(caCerts string) (*x509.CertPool, error) {
+	// Load the host's trusted certs into memory
+	certPool, _ := x509.SystemCertPool()
+	if certPool == nil {
+		certPool = x509.NewCertPool()
+	}
+
+	certChain := getCertChain(caCerts)
+
+	if len(certChain.Certificate) == 0 {
+		klog.Warning("No certificate found")
+	}
+
+	// Add CA certs from the channel config map to the cert pool
+	// It will not add duplicate certs
+	for _, cert := range certChain.Certificate {
+		x509Cert, err := x509.ParseCertificate(cert)
+		if err != nil {
+			return nil, err
+		}
+		klog.Info("Adding certificate -->" + x509Cert.Subject.String())
+		certPool.AddCert(x509Cert)
+	}
+
+	return certPool, nil
+}
+
 func getKnownHostFromURL(sshURL string, filepath string) error {
 	sshhostname := ""
 	sshhostport := ""
@@ -356,7 +334,7 @@ func getKnownHostFromURL(sshURL string, filepath string) error {
 	return nil
 }
 
-func getSSHOptions(options *git.CloneOptions, sshKey, passphrase []byte, knownhostsfile string, insecureSkipVerify bool) error {
+func getSSHOptions(options *git.CloneOptions, sshKey, passphrase []byte, hostKeyCallback ssh.HostKeyCallback) error {
 	publicKey := &gitssh.PublicKeys{}
 	publicKey.User = "git"
 
@@ -380,116 +358,75 @@ func getSSHOptions(options *git.CloneOptions, sshKey, passphrase []byte, knownho
 		publicKey.Signer = signer
 	}
 
-	if insecureSkipVerify {
-		klog.Info("Insecure ignore SSH host key")
-
-		publicKey.HostKeyCallback = ssh.InsecureIgnoreHostKey() // #nosec G106 this is optional and used only if users specify it in channel configuration
-	} else {
-		klog.Info("Using SSH known host keys")
-		callback, err := knownhosts.New(knownhostsfile)
-
-		if err != nil {
-			klog.Error("failed to get knownhosts ", err)
-			return err
-		}
-
-		publicKey.HostKeyCallback = callback
-	}
+	publicKey.HostKeyCallback = hostKeyCallback
 
 	options.Auth = publicKey
 
 	return nil
 }
 
-func getHTTPOptions(options *git.CloneOptions, caCerts string, insecureSkipVerify bool) error {
-	installProtocol := false
-
-	clientConfig := &tls.Config{MinVersion: tls.VersionTLS12}
-
-	// skip TLS certificate verification for Git servers with custom or self-signed certs
-	if insecureSkipVerify {
-		klog.Info("insecureSkipVerify = true, skipping Git server's certificate verification.")
-
-		clientConfig.InsecureSkipVerify = true
-
-		installProtocol = true
-	} else if !strings.EqualFold(caCerts, "") {
-		klog.Info("Adding Git server's CA certificate to trust certificate pool")
-
-		// Load the host's trusted certs into memory
-		certPool, _ := x509.SystemCertPool()
-		if certPool == nil {
-			certPool = x509.NewCertPool()
-		}
-
-		certChain := getCertChain(caCerts)
+// getHTTPTransport returns the transport of the git HTTPS requests with the TLS settings and the proxy of the
+// source, a nil proxy uses the proxy of the environment. It returns nil for the default transport.
+func getHTTPTransport(gitURL string, settings *sourceTLS, proxy func(*http.Request) (*url.URL, error)) (
+	http.RoundTripper, error) {
+	u, err := url.Parse(gitURL)
+	if err != nil {
+		return nil, err
+	}
 
-		if len(certChain.Certificate) == 0 {
-			klog.Warning("No certificate found")
-		}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, nil
+	}
 
-		// Add CA certs from the channel config map to the cert pool
-		// It will not add duplicate certs
-		for _, cert := range certChain.Certificate {
-			x509Cert, err := x509.ParseCertificate(cert)
-			if err != nil {
-				return err
-			}
-			klog.Info("Adding certificate -->" + x509Cert.Subject.String())
-			certPool.AddCert(x509Cert)
-		}
+	if settings.isDefault() && proxy == nil {
+		return nil, nil
+	}
 
-		clientConfig.RootCAs = certPool
+	clientConfig, err := settings.config()
+	if err != nil {
+		return nil, err
+	}
 
-		installProtocol = true
+	if settings.clientCert != nil {
+		klog.Info("Presenting a client certificate to Git server ", u.Host)
 	}
 
-	if installProtocol {
-		klog.Info("HTTP_PROXY = " + os.Getenv("HTTP_PROXY"))
-		klog.Info("HTTPS_PROXY = " + os.Getenv("HTTPS_PROXY"))
+	transportConfig := &http.Transport{
+		/* #nosec G402 */
+		TLSClientConfig: clientConfig,
+	}
 
-		transportConfig := &http.Transport{
-			/* #nosec G402 */
-			TLSClientConfig: clientConfig,
-		}
+	if proxy != nil {
+		transportConfig.Proxy = proxy
 
-		proxyURLEnv := ""
+		return transportConfig, nil
+	}
 
-		if os.Getenv("HTTPS_PROXY") != "" {
-			proxyURLEnv = os.Getenv("HTTPS_PROXY")
-		} else if os.Getenv("HTTP_PROXY") != "" {
-			proxyURLEnv = os.Getenv("HTTP_PROXY")
-		}
+	klog.Info("HTTP_PROXY = " + os.Getenv("HTTP_PROXY"))
+	klog.Info("HTTPS_PROXY = " + os.Getenv("HTTPS_PROXY"))
 
-		if proxyURLEnv != "" {
-			proxyURL, err := url.Parse(proxyURLEnv)
+	proxyURLEnv := ""
 
-			if err != nil {
-				klog.Error(err.Error())
-				return err
-			}
+	if os.Getenv("HTTPS_PROXY") != "" {
+		proxyURLEnv = os.Getenv("HTTPS_PROXY")
+	} else if os.Getenv("HTTP_PROXY") != "" {
+		proxyURLEnv = os.Getenv("HTTP_PROXY")
+	}
 
-			transportConfig.Proxy = http.ProxyURL(proxyURL)
+	if proxyURLEnv != "" {
+		proxyURL, err := url.Parse(proxyURLEnv)
 
-			klog.Info("setting HTTP transport proxy to " + proxyURLEnv)
+		if err != nil {
+			klog.Error(err.Error())
+			return nil, err
 		}
 
-		customClient := &http.Client{
-			/* #nosec G402 */
-			Transport: transportConfig,
-
-			// 15 second timeout
-			Timeout: 15 * time.Second,
-
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse
-			},
-		}
+		transportConfig.Proxy = http.ProxyURL(proxyURL)
 
-		gitclient.InstallProtocol("https", githttp.NewClient(customClient))
+		klog.Info("setting HTTP transport proxy to " + proxyURLEnv)
 	}
 
-	return nil
+	return transportConfig, nil
 }
 
 //DownloadChartFromHelmRepo downloads a chart into the chartDir
@@ -497,51 +434,63 @@ func DownloadChartFromHelmRepo(configMap *corev1.ConfigMap,
 	secret *corev1.Secret,
 	destRepo string,
 	s *appv1.HelmRelease) (chartDir string, err error) {
-	if s.Repo.Source.HelmRepo == nil {
-		err := fmt.Errorf("helmrepo type but Spec.HelmRepo is not defined")
+	chart, err := fetchChartFrom(helmRepoChartSource{}, &ChartRequest{
+		HelmRelease: s,
+		Source:      s.Repo.Source,
+		ConfigMap:   configMap,
+		Secret:      secret,
+		DestDir:     destRepo,
+	})
+	if err != nil {
 		return "", err
 	}
 
-	var urlsError string
-
-	for _, url := range s.Repo.Source.HelmRepo.Urls {
-		chartDir, err := downloadChartFromURL(configMap, secret, destRepo, s, url)
-		if err == nil {
-			return chartDir, nil
-		}
-
-		urlsError += " - url: " + url + " error: " + err.Error()
-	}
-
-	return "", fmt.Errorf("failed to download chart from helm repo. " + urlsError)
+	return chart.Dir, nil
 }
 
-func downloadChartFromURL(configMap *corev1.ConfigMap,
+// downloadChartArchive downloads the chart archive at the url into destRepo and returns its path
+func downloadChartArchive(configMap *corev1.ConfigMap,
 	secret *corev1.Secret,
 	destRepo string,
 	s *appv1.HelmRelease,
-	url string) (chartDir string, err error) {
-
-	digestTrim := s.Repo.Digest
-	if digestTrim != "" {
-		if len(digestTrim) >= 6 {
-			digestTrim = digestTrim[0:6]
-		}
-	}
-
-	chartZip, downloadErr := downloadFile(s.Namespace, configMap, url, secret, destRepo, s.Repo.InsecureSkipVerify, digestTrim)
+	url string) (string, error) {
+	chartZip, downloadErr := downloadFile(s.Namespace, configMap, url, secret, destRepo, s.Repo.InsecureSkipVerify,
+		digestTrim(s.Repo.Digest))
 	if downloadErr != nil {
 		klog.Error(downloadErr, " - url: ", url)
 		return "", downloadErr
 	}
 
-	r, downloadErr := os.Open(filepath.Clean(chartZip))
-	if downloadErr != nil {
-		klog.Error(downloadErr, " - Failed to open: ", chartZip, " using url: ", url)
-		return "", downloadErr
+	return chartZip, nil
+}
+
+// digestTrim returns the first 6 characters of the digest, they suffix the name of the downloaded files
+func digestTrim(digest string) string {
+	if len(digest) >= 6 {
+		return digest[0:6]
 	}
 
-	chartDir = filepath.Join(destRepo, s.Repo.ChartName)
+	return digest
+}
+
+// expandChartArchive expands the chart archive chartZip downloaded from url in destRepo and returns the chart
+// directory and the digest of the archive
+func expandChartArchive(destRepo, chartName, chartZip, url string) (chartDir, revision string, err error) {
+	revision, err = fileDigest(chartZip)
+	if err != nil {
+		klog.Error(err, " - Failed to compute the digest of: ", chartZip, " using url: ", url)
+		return "", "", err
+	}
+
+	r, err := os.Open(filepath.Clean(chartZip))
+	if err != nil {
+		klog.Error(err, " - Failed to open: ", chartZip, " using url: ", url)
+		return "", "", err
+	}
+
+	defer closeHelper(r)
+
+	chartDir = filepath.Join(destRepo, chartName)
 	chartDir = filepath.Clean(chartDir)
 	//Clean before untar
 	err = os.RemoveAll(chartDir)
@@ -560,10 +509,27 @@ func downloadChartFromURL(configMap *corev1.ConfigMap,
 
 		klog.Error(err, "- Failed to unzip: ", chartZip, " using url: ", url)
 
+		return "", "", err
+	}
+
+	return chartDir, revision, nil
+}
+
+// fileDigest returns the sha256 digest of a file in the <algorithm>:<hex> format
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
 		return "", err
 	}
 
-	return chartDir, nil
+	defer closeHelper(f)
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
 }
 
 //downloadFile downloads a files and post it in the chartsDir.
@@ -650,12 +616,18 @@ func downloadFileHTTP(parentNamespace string, configMap *corev1.ConfigMap,
 	}
 
 	if os.IsNotExist(err) {
-		httpClient, downloadErr := GetHelmRepoClient(parentNamespace, configMap, insecureSkipVerify)
+		httpClient, downloadErr := newSourceHTTPClient(configMap, secret, fileURL, insecureSkipVerify)
 		if downloadErr != nil {
 			klog.Error(downloadErr, " - Failed to create httpClient")
 			return downloadErr
 		}
 
+		credential, downloadErr := httpCredential(secret, fileURL)
+		if downloadErr != nil {
+			klog.Error(downloadErr, " - Failed to get the credential of: ", fileURL)
+			return downloadErr
+		}
+
 		var req *http.Request
 
 		req, downloadErr = http.NewRequest(http.MethodGet, fileURL, nil)
@@ -664,8 +636,8 @@ func downloadFileHTTP(parentNamespace string, configMap *corev1.ConfigMap,
 			return downloadErr
 		}
 
-		if secret != nil && secret.Data != nil {
-			req.SetBasicAuth(string(secret.Data["user"]), GetPassword(secret))
+		if credential != nil {
+			credential.setAuth(req)
 		}
 
 		var resp *http.Response
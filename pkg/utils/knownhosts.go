@@ -0,0 +1,165 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// knownHostsKey is the key of the secret or config map holding the known_hosts of the ssh urls
+	knownHostsKey = "knownHosts"
+	// sshHostKeyFingerprintsKey is the key of the config map holding the SHA256 fingerprints the host keys
+	// must match
+	sshHostKeyFingerprintsKey = "sshHostKeyFingerprints"
+	// sshKeyscanKey is the key of the config map that opts in to trusting the host keys of ssh-keyscan
+	sshKeyscanKey = "sshKeyscan"
+)
+
+// sshHostKeyCallback returns the verification of the host key of the ssh url. The host keys are the
+// knownHosts of the secret or of the config map, and the sshHostKeyFingerprints of the config map pin them.
+// ssh-keyscan only provides the known hosts when the sshKeyscan of the config map is true.
+func sshHostKeyCallback(configMap *corev1.ConfigMap,
+	secret *corev1.Secret,
+	sshURL, dir string,
+	insecureSkipVerify bool) (ssh.HostKeyCallback, error) {
+	if insecureSkipVerify {
+		klog.Info("Insecure ignore SSH host key")
+
+		return ssh.InsecureIgnoreHostKey(), nil // #nosec G106 this is optional and used only if users specify it in channel configuration
+	}
+
+	knownHosts := ""
+	if secret != nil {
+		knownHosts = strings.TrimSpace(string(secret.Data[knownHostsKey]))
+	}
+
+	var fingerprints []string
+
+	keyscan := false
+
+	if configMap != nil {
+		if knownHosts == "" {
+			knownHosts = strings.TrimSpace(configMap.Data[knownHostsKey])
+		}
+
+		fingerprints = sshFingerprints(configMap.Data[sshHostKeyFingerprintsKey])
+
+		if v := configMap.Data[sshKeyscanKey]; v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				klog.Error(err, " - Unable to parse sshKeyscan", v)
+				return nil, err
+			}
+
+			keyscan = b
+		}
+	}
+
+	knownHostsFile := filepath.Join(dir, "known_hosts")
+
+	switch {
+	case knownHosts != "":
+		klog.Info("Using SSH known host keys")
+
+		if err := ioutil.WriteFile(knownHostsFile, []byte(knownHosts+"\n"), 0600); err != nil {
+			klog.Error("failed to write known_hosts file: ", err)
+			return nil, err
+		}
+	case keyscan:
+		if err := getKnownHostFromURL(sshURL, knownHostsFile); err != nil {
+			return nil, err
+		}
+	case len(fingerprints) == 0:
+		return nil, fmt.Errorf("no known host keys for %s: set the %s of the secret or config map, the %s of "+
+			"the config map, or %s to true in the config map to trust the keys of ssh-keyscan",
+			sshURL, knownHostsKey, sshHostKeyFingerprintsKey, sshKeyscanKey)
+	}
+
+	var knownHostsCallback ssh.HostKeyCallback
+
+	if knownHosts != "" || keyscan {
+		callback, err := knownhosts.New(knownHostsFile)
+		if err != nil {
+			klog.Error("failed to get knownhosts ", err)
+			return nil, err
+		}
+
+		knownHostsCallback = callback
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		fingerprint := ssh.FingerprintSHA256(key)
+
+		if knownHostsCallback != nil {
+			err := knownHostsCallback(hostname, remote, key)
+
+			var keyErr *knownhosts.KeyError
+
+			switch {
+			case errors.As(err, &keyErr) && len(keyErr.Want) > 0:
+				return fmt.Errorf("ssh host key %s of %s does not match its known host keys", fingerprint, hostname)
+			case errors.As(err, &keyErr):
+				return fmt.Errorf("ssh host %s with key %s is not in the known hosts", hostname, fingerprint)
+			case err != nil:
+				return err
+			}
+		}
+
+		if len(fingerprints) > 0 && !containsString(fingerprints, fingerprint) {
+			return fmt.Errorf("ssh host key %s of %s does not match the pinned fingerprints %s",
+				fingerprint, hostname, strings.Join(fingerprints, ", "))
+		}
+
+		return nil
+	}, nil
+}
+
+// sshFingerprints returns the comma or space separated fingerprints, prefixed with SHA256: if they are not
+func sshFingerprints(s string) []string {
+	fingerprints := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+
+	for i, fingerprint := range fingerprints {
+		if !strings.HasPrefix(fingerprint, "SHA256:") {
+			fingerprints[i] = "SHA256:" + fingerprint
+		}
+	}
+
+	return fingerprints
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func newSSHHostKey(t *testing.T) ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	key, err := ssh.NewPublicKey(pub)
+	assert.NoError(t, err)
+
+	return key
+}
+
+func TestSSHHostKeyCallback(t *testing.T) {
+	dir, err := ioutil.TempDir("/tmp", "knownhosts")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	hostKey := newSSHHostKey(t)
+	otherKey := newSSHHostKey(t)
+	remote := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 22}
+	sshURL := "git@git.example.com:org/repo.git"
+
+	// ssh-keyscan is not used without opt-in
+	_, err = sshHostKeyCallback(nil, nil, sshURL, dir, false)
+	assert.Error(t, err)
+
+	callback, err := sshHostKeyCallback(nil, nil, sshURL, dir, true)
+	assert.NoError(t, err)
+	assert.NoError(t, callback("git.example.com:22", remote, otherKey))
+
+	secret := &corev1.Secret{Data: map[string][]byte{
+		knownHostsKey: []byte(knownhosts.Line([]string{"git.example.com"}, hostKey)),
+	}}
+
+	callback, err = sshHostKeyCallback(nil, secret, sshURL, dir, false)
+	assert.NoError(t, err)
+	assert.NoError(t, callback("git.example.com:22", remote, hostKey))
+
+	err = callback("git.example.com:22", remote, otherKey)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does not match its known host keys")
+	}
+
+	err = callback("other.example.com:22", remote, hostKey)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "is not in the known hosts")
+	}
+
+	// the known hosts of the config map
+	configMap := &corev1.ConfigMap{Data: map[string]string{
+		knownHostsKey: knownhosts.Line([]string{"git.example.com"}, hostKey),
+	}}
+
+	callback, err = sshHostKeyCallback(configMap, nil, sshURL, dir, false)
+	assert.NoError(t, err)
+	assert.NoError(t, callback("git.example.com:22", remote, hostKey))
+
+	// the fingerprints pin the host key with or without known hosts
+	fingerprint := strings.TrimPrefix(ssh.FingerprintSHA256(hostKey), "SHA256:")
+
+	for _, secret := range []*corev1.Secret{nil, secret} {
+		callback, err = sshHostKeyCallback(&corev1.ConfigMap{Data: map[string]string{
+			sshHostKeyFingerprintsKey: ssh.FingerprintSHA256(otherKey) + ", " + fingerprint,
+		}}, secret, sshURL, dir, false)
+		assert.NoError(t, err)
+		assert.NoError(t, callback("git.example.com:22", remote, hostKey))
+	}
+
+	callback, err = sshHostKeyCallback(&corev1.ConfigMap{Data: map[string]string{
+		sshHostKeyFingerprintsKey: fingerprint,
+	}}, nil, sshURL, dir, false)
+	assert.NoError(t, err)
+
+	err = callback("git.example.com:22", remote, otherKey)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does not match the pinned fingerprints")
+	}
+
+	_, err = sshHostKeyCallback(&corev1.ConfigMap{Data: map[string]string{sshKeyscanKey: "maybe"}}, nil,
+		sshURL, dir, false)
+	assert.Error(t, err)
+}
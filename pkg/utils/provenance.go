@@ -0,0 +1,119 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"helm.sh/helm/v3/pkg/provenance"
+	"k8s.io/klog"
+)
+
+// keyringKey is the key of the keyring secret holding the public keyring
+const keyringKey = "keyring"
+
+// ErrChartNotVerified is returned when the provenance of a chart can not be verified
+var ErrChartNotVerified = errors.New("chart provenance verification failed")
+
+// verifyChartProvenance downloads the provenance file of the chart archive downloaded from the location and
+// verifies it with the keyring of the keyring secret of the helm repo. It returns the identity of the signer.
+func verifyChartProvenance(req *ChartRequest, location, chartZip string) (string, error) {
+	keyringRef := req.Source.HelmRepo.KeyringSecretRef
+	if keyringRef == nil {
+		return "", fmt.Errorf("%w: helmRepo.verify is set but helmRepo.keyringSecretRef is not", ErrChartNotVerified)
+	}
+
+	if req.Client == nil {
+		return "", fmt.Errorf("%w: no client to read keyring secret %s", ErrChartNotVerified, keyringRef.Name)
+	}
+
+	keyringSecret, err := GetSecret(req.Client, req.HelmRelease.Namespace, keyringRef)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to get keyring secret %s: %v", ErrChartNotVerified, keyringRef.Name, err)
+	}
+
+	keyring := keyringSecret.Data[keyringKey]
+	if len(keyring) == 0 {
+		return "", fmt.Errorf("%w: keyring secret %s has no %s key", ErrChartNotVerified, keyringRef.Name, keyringKey)
+	}
+
+	provFile, err := downloadChartArchive(req.ConfigMap, req.Secret, req.DestDir, req.HelmRelease, location+".prov")
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to download the provenance file of %s: %v", ErrChartNotVerified, location, err)
+	}
+
+	// the provenance file holds the digest of the archive under its name in the repo while the downloaded
+	// archive may have a digest suffix
+	verifyDir, err := ioutil.TempDir(req.DestDir, ".verify")
+	if err != nil {
+		return "", err
+	}
+
+	defer os.RemoveAll(verifyDir)
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+
+	chartCopy := filepath.Join(verifyDir, path.Base(u.Path))
+	if err := os.Link(chartZip, chartCopy); err != nil {
+		return "", err
+	}
+
+	keyringFile := filepath.Join(verifyDir, "keyring.gpg")
+	if err := ioutil.WriteFile(keyringFile, keyring, 0600); err != nil {
+		return "", err
+	}
+
+	signatory, err := provenance.NewFromKeyring(keyringFile, "")
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to load keyring secret %s: %v", ErrChartNotVerified, keyringRef.Name, err)
+	}
+
+	verification, err := signatory.Verify(chartCopy, provFile)
+	if err != nil {
+		// the files that failed the verification are downloaded again on the next attempt
+		_ = os.Remove(provFile)
+		_ = os.Remove(chartZip)
+
+		return "", fmt.Errorf("%w: %s: %v", ErrChartNotVerified, location, err)
+	}
+
+	identities := []string{}
+	for name := range verification.SignedBy.Identities {
+		identities = append(identities, name)
+	}
+
+	sort.Strings(identities)
+
+	signedBy := ""
+	if len(identities) > 0 {
+		signedBy = identities[0]
+	}
+
+	klog.Info("Verified chart ", location, " signed by ", signedBy, " with hash ", verification.FileHash)
+
+	return signedBy, nil
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/openpgp" //nolint
+	"helm.sh/helm/v3/pkg/provenance"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+// newKeyring returns the public keyring of a new signing key and the provenance file of the chart signed by it
+func newKeyring(t *testing.T, name, chartPath string) ([]byte, string) {
+	entity, err := openpgp.NewEntity(name, "", "signer@example.com", nil)
+	assert.NoError(t, err)
+
+	secretKeyring := &bytes.Buffer{}
+	assert.NoError(t, entity.SerializePrivate(secretKeyring, nil))
+
+	dir, err := ioutil.TempDir("/tmp", "keyring")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "secring.gpg"), secretKeyring.Bytes(), 0600))
+
+	signatory, err := provenance.NewFromKeyring(filepath.Join(dir, "secring.gpg"), name)
+	assert.NoError(t, err)
+
+	prov, err := signatory.ClearSign(chartPath)
+	assert.NoError(t, err)
+
+	publicKeyring := &bytes.Buffer{}
+	assert.NoError(t, entity.Serialize(publicKeyring))
+
+	return publicKeyring.Bytes(), prov
+}
+
+func TestFetchChartProvenance(t *testing.T) {
+	chartPath := "../../test/helmrepo/subscription-release-test-1-0.1.0.tgz"
+
+	chartZip, err := ioutil.ReadFile(chartPath)
+	assert.NoError(t, err)
+
+	keyring, prov := newKeyring(t, "Chart Signer", chartPath)
+	otherKeyring, _ := newKeyring(t, "Other Signer", chartPath)
+
+	files := map[string][]byte{
+		"/subscription-release-test-1-0.1.0.tgz":          chartZip,
+		"/subscription-release-test-1-0.1.0.tgz.prov":     []byte(prov),
+		"/unsigned/subscription-release-test-1-0.1.0.tgz": chartZip,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "keyring", Namespace: "default"},
+			Data:       map[string][]byte{keyringKey: keyring},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-keyring", Namespace: "default"},
+			Data:       map[string][]byte{keyringKey: otherKeyring},
+		},
+	).Build()
+
+	tests := []struct {
+		name     string
+		path     string
+		keyring  string
+		signedBy string
+	}{
+		{name: "signed", path: "/", keyring: "keyring", signedBy: "Chart Signer <signer@example.com>"},
+		{name: "signed by another key", path: "/", keyring: "other-keyring"},
+		{name: "no provenance file", path: "/unsigned/", keyring: "keyring"},
+		{name: "no keyring secret", path: "/", keyring: "missing"},
+	}
+
+	for _, tt := range tests {
+		dir, err := ioutil.TempDir("/tmp", "charts")
+		assert.NoError(t, err)
+
+		defer os.RemoveAll(dir)
+
+		chart, err := FetchChartWithClient(c, nil, nil, dir, &appv1.HelmRelease{
+			ObjectMeta: metav1.ObjectMeta{Name: "subscription-release-test-1-cr", Namespace: "default"},
+			Repo: appv1.HelmReleaseRepo{
+				Source: &appv1.Source{
+					SourceType: appv1.HelmRepoSourceType,
+					HelmRepo: &appv1.HelmRepo{
+						Urls:             []string{server.URL + tt.path + "subscription-release-test-1-0.1.0.tgz"},
+						Verify:           true,
+						KeyringSecretRef: &corev1.ObjectReference{Name: tt.keyring},
+					},
+				},
+				ChartName: "subscription-release-test-1",
+			},
+		})
+
+		if tt.signedBy == "" {
+			assert.True(t, errors.Is(err, ErrChartNotVerified), tt.name)
+			continue
+		}
+
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, tt.signedBy, chart.SignedBy, tt.name)
+		}
+	}
+}
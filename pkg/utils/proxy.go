@@ -0,0 +1,94 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// the keys of the configMapRef of a source that set its proxy
+	httpProxyKey  = "httpProxy"
+	httpsProxyKey = "httpsProxy"
+	noProxyKey    = "noProxy"
+
+	// the keys of the secretRef of a source that authenticate to its proxy
+	proxyUserKey     = "proxyUser"
+	proxyPasswordKey = "proxyPassword"
+)
+
+// sourceProxy returns the proxy of the requests of a source set by the httpProxy, httpsProxy and noProxy
+// of its config map, authenticated with the proxyUser and proxyPassword of its secret. It returns nil when
+// the config map sets no proxy, the requests then use the proxy of the environment.
+func sourceProxy(configMap *corev1.ConfigMap, secret *corev1.Secret) (func(*http.Request) (*url.URL, error), error) {
+	if configMap == nil || (configMap.Data[httpProxyKey] == "" && configMap.Data[httpsProxyKey] == "") {
+		return nil, nil
+	}
+
+	config := &httpproxy.Config{NoProxy: strings.TrimSpace(configMap.Data[noProxyKey])}
+
+	var err error
+
+	if config.HTTPProxy, err = proxyURLWithAuth(configMap.Data[httpProxyKey], secret); err != nil {
+		return nil, err
+	}
+
+	if config.HTTPSProxy, err = proxyURLWithAuth(configMap.Data[httpsProxyKey], secret); err != nil {
+		return nil, err
+	}
+
+	klog.V(3).Info("Using the proxies of config map ", configMap.Name, ", no proxy for: ", config.NoProxy)
+
+	proxyFunc := config.ProxyFunc()
+
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}, nil
+}
+
+// proxyURLWithAuth returns the proxy url with the user and password of the secret, if any
+func proxyURLWithAuth(proxyURL string, secret *corev1.Secret) (string, error) {
+	proxyURL = strings.TrimSpace(proxyURL)
+	if proxyURL == "" {
+		return "", nil
+	}
+
+	if secret == nil || len(secret.Data[proxyUserKey]) == 0 {
+		return proxyURL, nil
+	}
+
+	if !strings.Contains(proxyURL, "://") {
+		proxyURL = "http://" + proxyURL
+	}
+
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the proxy url %s: %w", proxyURL, err)
+	}
+
+	u.User = url.UserPassword(strings.TrimSpace(string(secret.Data[proxyUserKey])),
+		strings.TrimSpace(string(secret.Data[proxyPasswordKey])))
+
+	return u.String(), nil
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// repositoriesYAMLKey is the key of the secret listing the credentials of the repositories, in the format of
+// the helm repositories.yaml
+const repositoriesYAMLKey = "repositories.yaml"
+
+// RepoCredential holds the credentials of the urls that start with URL. A URL without scheme matches
+// both http and https, a URL with only a host matches all the urls of the host.
+type RepoCredential struct {
+	Name        string `json:"name,omitempty"`
+	URL         string `json:"url"`
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"password,omitempty"`
+	BearerToken string `json:"bearerToken,omitempty"`
+	// CertData and KeyData are the PEM encoded client certificate and key
+	CertData string `json:"certData,omitempty"`
+	KeyData  string `json:"keyData,omitempty"`
+}
+
+type repositoriesFile struct {
+	Repositories []RepoCredential `json:"repositories"`
+}
+
+type dockerConfigJSON struct {
+	Auths map[string]dockerAuth `json:"auths"`
+}
+
+type dockerAuth struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	Auth          string `json:"auth,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+	RegistryToken string `json:"registrytoken,omitempty"`
+}
+
+// hasRepoCredentials returns true if the secret lists the credentials of several repositories
+func hasRepoCredentials(secret *corev1.Secret) bool {
+	if secret == nil {
+		return false
+	}
+
+	_, repositories := secret.Data[repositoriesYAMLKey]
+	_, dockerConfig := secret.Data[corev1.DockerConfigJsonKey]
+
+	return repositories || dockerConfig
+}
+
+// repoCredentials returns the credentials listed in the repositories.yaml or the .dockerconfigjson of the secret
+func repoCredentials(secret *corev1.Secret) ([]RepoCredential, error) {
+	credentials := []RepoCredential{}
+
+	if secret == nil {
+		return credentials, nil
+	}
+
+	if b, ok := secret.Data[repositoriesYAMLKey]; ok {
+		f := &repositoriesFile{}
+		if err := yaml.Unmarshal(b, f); err != nil {
+			return nil, fmt.Errorf("failed to parse the %s of secret %s: %w", repositoriesYAMLKey, secret.Name, err)
+		}
+
+		credentials = append(credentials, f.Repositories...)
+	}
+
+	if b, ok := secret.Data[corev1.DockerConfigJsonKey]; ok {
+		config := &dockerConfigJSON{}
+		if err := json.Unmarshal(b, config); err != nil {
+			return nil, fmt.Errorf("failed to parse the %s of secret %s: %w", corev1.DockerConfigJsonKey, secret.Name, err)
+		}
+
+		for url, auth := range config.Auths {
+			credential := RepoCredential{URL: url, Username: auth.Username, Password: auth.Password}
+
+			if auth.Auth != "" {
+				decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+				if err != nil {
+					return nil, fmt.Errorf("failed to decode the auth of %s in secret %s: %w", url, secret.Name, err)
+				}
+
+				credential.Username, credential.Password, _ = CutString(string(decoded), ":")
+			}
+
+			if auth.RegistryToken != "" {
+				credential.BearerToken = auth.RegistryToken
+			} else if auth.IdentityToken != "" {
+				credential.BearerToken = auth.IdentityToken
+			}
+
+			credentials = append(credentials, credential)
+		}
+	}
+
+	return credentials, nil
+}
+
+// repoCredentialFor returns the credential of the secret whose URL is the longest prefix of url, nil if none matches
+func repoCredentialFor(secret *corev1.Secret, url string) (*RepoCredential, error) {
+	credentials, err := repoCredentials(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	var match *RepoCredential
+
+	matchLen := -1
+
+	for i := range credentials {
+		prefix, ok := matchRepoURL(credentials[i].URL, url)
+		if ok && len(prefix) > matchLen {
+			match = &credentials[i]
+			matchLen = len(prefix)
+		}
+	}
+
+	return match, nil
+}
+
+// httpCredential returns the credential of the helm repo url: the matching credential of the repositories.yaml
+// or .dockerconfigjson of the secret if it has one, otherwise the user and password or the bearerToken of the secret
+func httpCredential(secret *corev1.Secret, url string) (*RepoCredential, error) {
+	if hasRepoCredentials(secret) {
+		return repoCredentialFor(secret, url)
+	}
+
+	if secret == nil || secret.Data == nil {
+		return nil, nil
+	}
+
+	if token := strings.TrimSpace(string(secret.Data[bearerTokenKey])); token != "" {
+		return &RepoCredential{URL: url, BearerToken: token}, nil
+	}
+
+	return &RepoCredential{URL: url, Username: string(secret.Data["user"]), Password: GetPassword(secret)}, nil
+}
+
+// setAuth sets the authorization of the request
+func (c *RepoCredential) setAuth(req *http.Request) {
+	if c.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.BearerToken)
+		return
+	}
+
+	if c.Username != "" || c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+}
+
+// clientCertificate returns the client certificate of the credential, nil if it has none
+func (c *RepoCredential) clientCertificate() (*tls.Certificate, error) {
+	if c.CertData == "" && c.KeyData == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.X509KeyPair([]byte(c.CertData), []byte(c.KeyData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the client certificate of %s: %w", c.URL, err)
+	}
+
+	return &cert, nil
+}
+
+// clientTLSFor returns the client certificate of the url, either the certData and keyData of its repository
+// credential or the tls.crt and tls.key of the secret, and the CA certificates of the ca.crt of the secret
+func clientTLSFor(secret *corev1.Secret, url string) (*tls.Certificate, string, error) {
+	if secret == nil || secret.Data == nil {
+		return nil, "", nil
+	}
+
+	caCerts := string(secret.Data[corev1.ServiceAccountRootCAKey])
+
+	if hasRepoCredentials(secret) {
+		credential, err := repoCredentialFor(secret, url)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if credential != nil {
+			cert, err := credential.clientCertificate()
+			if err != nil || cert != nil {
+				return cert, caCerts, err
+			}
+		}
+	}
+
+	if len(secret.Data[corev1.TLSCertKey]) == 0 && len(secret.Data[corev1.TLSPrivateKeyKey]) == 0 {
+		return nil, caCerts, nil
+	}
+
+	cert, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load the client certificate of secret %s: %w", secret.Name, err)
+	}
+
+	return &cert, caCerts, nil
+}
+
+// matchRepoURL returns the normalized prefix and true if the url starts with the credential url
+func matchRepoURL(credentialURL, url string) (string, bool) {
+	prefix := strings.TrimSuffix(credentialURL, "/")
+	target := url
+
+	if !strings.Contains(prefix, "://") {
+		target = stripScheme(url)
+	}
+
+	if prefix == "" {
+		return "", false
+	}
+
+	if target == prefix || strings.HasPrefix(target, prefix+"/") || strings.HasPrefix(target, prefix+"?") {
+		return prefix, true
+	}
+
+	return "", false
+}
+
+func stripScheme(url string) string {
+	if _, rest, found := CutString(url, "://"); found {
+		return rest
+	}
+
+	return url
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestRepoCredentialFor(t *testing.T) {
+	secret := &corev1.Secret{
+		Data: map[string][]byte{
+			repositoriesYAMLKey: []byte(`repositories:
+- name: all
+  url: https://charts.example.com
+  username: all
+  password: all-password
+- name: stable
+  url: https://charts.example.com/stable/
+  bearerToken: stable-token
+- name: mirror
+  url: mirror.example.com:8443
+  username: mirror
+  password: mirror-password
+`),
+			// registry:registry-password
+			corev1.DockerConfigJsonKey: []byte(`{"auths":{"registry.example.com":{"auth":"cmVnaXN0cnk6cmVnaXN0cnktcGFzc3dvcmQ="}}}`),
+		},
+	}
+
+	tests := []struct {
+		url  string
+		name string
+	}{
+		{url: "https://charts.example.com/incubator/chart-0.1.0.tgz", name: "all"},
+		{url: "https://charts.example.com/stable/chart-0.1.0.tgz", name: "stable"},
+		{url: "https://charts.example.com/stable-old/chart-0.1.0.tgz", name: "all"},
+		{url: "http://mirror.example.com:8443/chart-0.1.0.tgz", name: "mirror"},
+		{url: "https://mirror.example.com/chart-0.1.0.tgz", name: ""},
+		{url: "https://charts.example.com.evil.com/chart-0.1.0.tgz", name: ""},
+		{url: "https://registry.example.com/charts/chart-0.1.0.tgz", name: "registry"},
+	}
+
+	for _, tt := range tests {
+		credential, err := repoCredentialFor(secret, tt.url)
+		assert.NoError(t, err)
+
+		switch {
+		case tt.name == "":
+			assert.Nil(t, credential, tt.url)
+		case tt.name == "registry":
+			assert.Equal(t, "registry", credential.Username, tt.url)
+			assert.Equal(t, "registry-password", credential.Password, tt.url)
+		default:
+			assert.Equal(t, tt.name, credential.Name, tt.url)
+		}
+	}
+
+	_, err := repoCredentialFor(&corev1.Secret{Data: map[string][]byte{repositoriesYAMLKey: []byte("repositories: {")}},
+		"https://charts.example.com")
+	assert.Error(t, err)
+}
+
+func TestFetchChartPerHostCredentials(t *testing.T) {
+	chartZip, err := ioutil.ReadFile("../../test/helmrepo/subscription-release-test-1-0.1.0.tgz")
+	assert.NoError(t, err)
+
+	// each server only serves the chart with its own credentials
+	newServer := func(authorization string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != authorization {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			_, _ = w.Write(chartZip)
+		}))
+	}
+
+	basicServer := newServer("Basic dXNlcjpwYXNzd29yZA==")
+	defer basicServer.Close()
+
+	bearerServer := newServer("Bearer token")
+	defer bearerServer.Close()
+
+	secret := &corev1.Secret{
+		Data: map[string][]byte{
+			repositoriesYAMLKey: []byte(fmt.Sprintf(`repositories:
+- url: %s
+  username: user
+  password: password
+- url: %s
+  bearerToken: token
+`, basicServer.URL, strings.TrimPrefix(bearerServer.URL, "http://"))),
+		},
+	}
+
+	for _, url := range []string{basicServer.URL, bearerServer.URL} {
+		dir, err := ioutil.TempDir("/tmp", "charts")
+		assert.NoError(t, err)
+
+		defer os.RemoveAll(dir)
+
+		hr := &appv1.HelmRelease{
+			ObjectMeta: metav1.ObjectMeta{Name: "subscription-release-test-1-cr", Namespace: "default"},
+			Repo: appv1.HelmReleaseRepo{
+				Source: &appv1.Source{
+					SourceType: appv1.HelmRepoSourceType,
+					HelmRepo:   &appv1.HelmRepo{Urls: []string{url + "/subscription-release-test-1-0.1.0.tgz"}},
+				},
+				ChartName: "subscription-release-test-1",
+			},
+		}
+
+		_, err = FetchChart(nil, secret, dir, hr)
+		assert.NoError(t, err, url)
+	}
+}
@@ -0,0 +1,31 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCutString(t *testing.T) {
+	before, after, found := CutString("user:pass:word", ":")
+	assert.Equal(t, []interface{}{"user", "pass:word", true}, []interface{}{before, after, found})
+
+	before, after, found = CutString("user", ":")
+	assert.Equal(t, []interface{}{"user", "", false}, []interface{}{before, after, found})
+}
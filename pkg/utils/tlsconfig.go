@@ -0,0 +1,162 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// the keys of the configMapRef of a source that set its TLS settings
+	caCertsKey            = "caCerts"
+	insecureSkipVerifyKey = "insecureSkipVerify"
+	tlsMinVersionKey      = "tlsMinVersion"
+	tlsServerNameKey      = "tlsServerName"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// sourceTLS is the TLS settings of the HTTPS requests of a source
+type sourceTLS struct {
+	insecureSkipVerify bool
+	// caCerts are PEM encoded CA certificates trusted in addition to the system ones
+	caCerts    string
+	clientCert *tls.Certificate
+	minVersion uint16
+	serverName string
+}
+
+// sourceTLSFor returns the TLS settings of the requests to the url: the insecureSkipVerify, caCerts,
+// tlsMinVersion and tlsServerName of the config map, and the client certificate and ca.crt of the secret
+func sourceTLSFor(configMap *corev1.ConfigMap, secret *corev1.Secret, url string,
+	insecureSkipVerify bool) (*sourceTLS, error) {
+	settings := &sourceTLS{
+		insecureSkipVerify: insecureSkipVerify,
+		minVersion:         tls.VersionTLS12,
+	}
+
+	if configMap != nil {
+		if v := configMap.Data[insecureSkipVerifyKey]; v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				klog.Error(err, " - Unable to parse insecureSkipVerify", v)
+				return nil, err
+			}
+
+			settings.insecureSkipVerify = settings.insecureSkipVerify || b
+		}
+
+		if v := configMap.Data[tlsMinVersionKey]; v != "" {
+			version, ok := tlsVersions[strings.TrimPrefix(strings.TrimSpace(v), "TLS")]
+			if !ok {
+				return nil, fmt.Errorf("unsupported %s %q of config map %s, expecting 1.2 or 1.3",
+					tlsMinVersionKey, v, configMap.Name)
+			}
+
+			settings.minVersion = version
+		}
+
+		settings.caCerts = configMap.Data[caCertsKey]
+		settings.serverName = strings.TrimSpace(configMap.Data[tlsServerNameKey])
+	}
+
+	clientCert, secretCACerts, err := clientTLSFor(secret, url)
+	if err != nil {
+		return nil, err
+	}
+
+	settings.clientCert = clientCert
+
+	if secretCACerts != "" {
+		settings.caCerts = strings.TrimSpace(settings.caCerts + "\n" + secretCACerts)
+	}
+
+	return settings, nil
+}
+
+// isDefault returns true if the settings are the ones of the default transport
+func (s *sourceTLS) isDefault() bool {
+	return !s.insecureSkipVerify && s.caCerts == "" && s.clientCert == nil && s.serverName == "" &&
+		s.minVersion == tls.VersionTLS12
+}
+
+// config returns the TLS client config of the settings. The caCerts are merged into the system cert pool.
+func (s *sourceTLS) config() (*tls.Config, error) {
+	/* #nosec G402 */
+	config := &tls.Config{
+		InsecureSkipVerify: s.insecureSkipVerify, // #nosec G402 InsecureSkipVerify conditionally
+		MinVersion:         s.minVersion,
+		ServerName:         s.serverName,
+	}
+
+	if s.insecureSkipVerify {
+		klog.Info("insecureSkipVerify = true, skipping server's certificate verification.")
+	} else if s.caCerts != "" {
+		certPool, err := newCertPool(s.caCerts)
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = certPool
+	}
+
+	if s.clientCert != nil {
+		config.Certificates = []tls.Certificate{*s.clientCert}
+	}
+
+	return config, nil
+}
+
+// newSourceHTTPClient returns the http client of the requests of a source to the url with the TLS settings
+// and the proxy of its config map and secret
+func newSourceHTTPClient(configMap *corev1.ConfigMap, secret *corev1.Secret, url string,
+	insecureSkipVerify bool) (*http.Client, error) {
+	settings, err := sourceTLSFor(configMap, secret, url, insecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := settings.config()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	proxy, err := sourceProxy(configMap, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if proxy != nil {
+		transport.Proxy = proxy
+	}
+
+	return &http.Client{Transport: transport, Timeout: 5 * time.Minute}, nil
+}
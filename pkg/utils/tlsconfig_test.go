@@ -0,0 +1,104 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1 "github.com/stolostron/multicloud-operators-subscription-release/pkg/apis/apps/v1"
+)
+
+func TestSourceTLSFor(t *testing.T) {
+	settings, err := sourceTLSFor(nil, nil, "https://charts.example.com", false)
+	assert.NoError(t, err)
+	assert.True(t, settings.isDefault())
+
+	settings, err = sourceTLSFor(&corev1.ConfigMap{Data: map[string]string{
+		caCertsKey:       "config-map-ca",
+		tlsMinVersionKey: "TLS1.3",
+		tlsServerNameKey: "charts.internal",
+	}}, &corev1.Secret{Data: map[string][]byte{corev1.ServiceAccountRootCAKey: []byte("secret-ca")}},
+		"https://charts.example.com", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "config-map-ca\nsecret-ca", settings.caCerts)
+	assert.Equal(t, uint16(tls.VersionTLS13), settings.minVersion)
+	assert.Equal(t, "charts.internal", settings.serverName)
+	assert.False(t, settings.isDefault())
+
+	// the insecureSkipVerify of the config map can not turn off the one of the source
+	settings, err = sourceTLSFor(&corev1.ConfigMap{Data: map[string]string{insecureSkipVerifyKey: "false"}}, nil,
+		"https://charts.example.com", true)
+	assert.NoError(t, err)
+	assert.True(t, settings.insecureSkipVerify)
+
+	_, err = sourceTLSFor(&corev1.ConfigMap{Data: map[string]string{tlsMinVersionKey: "1.0"}}, nil,
+		"https://charts.example.com", false)
+	assert.Error(t, err)
+}
+
+func TestFetchChartHelmRepoCACerts(t *testing.T) {
+	chartZip, err := ioutil.ReadFile("../../test/helmrepo/subscription-release-test-1-0.1.0.tgz")
+	assert.NoError(t, err)
+
+	// the certificate of the test server is valid for 127.0.0.1 and *.example.com
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(chartZip)
+	}))
+	defer server.Close()
+
+	fetch := func(configMap *corev1.ConfigMap) error {
+		dir, err := ioutil.TempDir("/tmp", "charts")
+		assert.NoError(t, err)
+
+		defer os.RemoveAll(dir)
+
+		_, err = FetchChart(configMap, nil, dir, &appv1.HelmRelease{
+			ObjectMeta: metav1.ObjectMeta{Name: "subscription-release-test-1-cr", Namespace: "default"},
+			Repo: appv1.HelmReleaseRepo{
+				Source: &appv1.Source{
+					SourceType: appv1.HelmRepoSourceType,
+					HelmRepo:   &appv1.HelmRepo{Urls: []string{server.URL + "/subscription-release-test-1-0.1.0.tgz"}},
+				},
+				ChartName: "subscription-release-test-1",
+			},
+		})
+
+		return err
+	}
+
+	caCerts := string(serverCACert(server))
+
+	assert.Error(t, fetch(nil))
+	assert.NoError(t, fetch(&corev1.ConfigMap{Data: map[string]string{caCertsKey: caCerts}}))
+	assert.NoError(t, fetch(&corev1.ConfigMap{Data: map[string]string{
+		caCertsKey:       caCerts,
+		tlsServerNameKey: "example.com",
+	}}))
+	assert.Error(t, fetch(&corev1.ConfigMap{Data: map[string]string{
+		caCertsKey:       caCerts,
+		tlsServerNameKey: "charts.example.org",
+	}}))
+}